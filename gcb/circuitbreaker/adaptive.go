@@ -0,0 +1,102 @@
+package circuitbreaker
+
+import (
+	"math"
+	"slices"
+	"time"
+)
+
+const (
+	// Number of the most recent successful latencies used by adaptive timeout
+	adaptiveWindowSize = 128
+	// Number of samples required before adaptive timeout takes effect
+	adaptiveMinSamples = 10
+)
+
+// latencyWindow keeps a fixed number of the most recent latencies.
+type latencyWindow struct {
+	samples []time.Duration
+	// Index where the next sample is written
+	next int
+	// Number of samples written, capped at capacity
+	size int
+}
+
+func newLatencyWindow(n int) *latencyWindow {
+	return &latencyWindow{samples: make([]time.Duration, n)}
+}
+
+func (w *latencyWindow) add(d time.Duration) {
+	w.samples[w.next] = d
+	w.next = (w.next + 1) % len(w.samples)
+	if w.size < len(w.samples) {
+		w.size++
+	}
+}
+
+// percentile returns the nearest-rank `p` percentile of the samples, where
+// `p` is a fraction in (0, 1].
+func (w *latencyWindow) percentile(p float64) time.Duration {
+	if w.size == 0 {
+		return 0
+	}
+
+	sorted := slices.Clone(w.samples[:w.size])
+	slices.Sort(sorted)
+
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	rank = max(0, min(rank, len(sorted)-1))
+
+	return sorted[rank]
+}
+
+// adaptiveTimeout derives the request timeout from observed latencies.
+type adaptiveTimeout struct {
+	percentile float64
+	multiplier float64
+	min        time.Duration
+	max        time.Duration
+	window     *latencyWindow
+}
+
+// observe records latency of a successful call.
+func (a *adaptiveTimeout) observe(d time.Duration) {
+	a.window.add(d)
+}
+
+// timeout returns `percentile * multiplier` bounded by `[min, max]`. Until
+// enough samples are collected `fallback` is used instead of the percentile.
+func (a *adaptiveTimeout) timeout(fallback time.Duration) time.Duration {
+	t := fallback
+	if a.window.size >= adaptiveMinSamples {
+		t = time.Duration(float64(a.window.percentile(a.percentile)) * a.multiplier)
+	}
+
+	return max(a.min, min(t, a.max))
+}
+
+// WithAdaptiveTimeout replaces the static timeout with one computed from the
+// latencies of recent successful calls. `percentile` is a fraction, e.g.
+// `0.99` for p99, the effective timeout is `percentile * multiplier` bounded
+// by `[min, max]`. Until enough calls succeed the static timeout is used,
+// bounded the same way.
+func WithAdaptiveTimeout(percentile, multiplier float64, min, max time.Duration) Option {
+	return func(cb *CircuitBreaker) {
+		cb.adaptive = &adaptiveTimeout{
+			percentile: percentile,
+			multiplier: multiplier,
+			min:        min,
+			max:        max,
+			window:     newLatencyWindow(adaptiveWindowSize),
+		}
+	}
+}
+
+// effectiveTimeout returns the timeout applied to the next call.
+func (cb *CircuitBreaker) effectiveTimeout() time.Duration {
+	if cb.adaptive == nil {
+		return cb.timeout
+	}
+
+	return cb.adaptive.timeout(cb.timeout)
+}
@@ -0,0 +1,112 @@
+package circuitbreaker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLatencyWindowPercentile(t *testing.T) {
+	w := newLatencyWindow(100)
+	// Latencies from 1ms to 100ms
+	for i := 100; i >= 1; i-- {
+		w.add(time.Duration(i) * time.Millisecond)
+	}
+
+	cases := map[float64]time.Duration{
+		0.5:  50 * time.Millisecond,
+		0.95: 95 * time.Millisecond,
+		0.99: 99 * time.Millisecond,
+		1:    100 * time.Millisecond,
+	}
+
+	for p, want := range cases {
+		if got := w.percentile(p); got != want {
+			t.Errorf("p%v should be `%s`, got `%s`", p*100, want, got)
+		}
+	}
+}
+
+func TestLatencyWindowEvictsOldest(t *testing.T) {
+	w := newLatencyWindow(10)
+	// Slow samples are pushed out by fast ones
+	for i := 0; i < 10; i++ {
+		w.add(time.Second)
+	}
+	for i := 0; i < 10; i++ {
+		w.add(time.Millisecond)
+	}
+
+	if got := w.percentile(1); got != time.Millisecond {
+		t.Errorf("window should keep only recent samples, got max `%s`", got)
+	}
+}
+
+func TestAdaptiveTimeout(t *testing.T) {
+	cb := NewCircuitBreaker(
+		5, 1, 2*time.Second, time.Second,
+		WithAdaptiveTimeout(0.95, 2, 10*time.Millisecond, 500*time.Millisecond),
+	)
+
+	if got := cb.effectiveTimeout(); got != 500*time.Millisecond {
+		t.Errorf("static timeout bounded by max should be used without samples, got `%s`", got)
+	}
+
+	// Known distribution, 20ms to 39ms
+	for i := 0; i < 20; i++ {
+		cb.adaptive.observe(time.Duration(20+i) * time.Millisecond)
+	}
+
+	// p95 is 38ms, doubled
+	if got := cb.effectiveTimeout(); got != 76*time.Millisecond {
+		t.Errorf("adaptive timeout should be `76ms`, got `%s`", got)
+	}
+}
+
+func TestAdaptiveTimeoutBounds(t *testing.T) {
+	cb := NewCircuitBreaker(
+		5, 1, 2*time.Second, time.Second,
+		WithAdaptiveTimeout(0.99, 3, 50*time.Millisecond, 200*time.Millisecond),
+	)
+
+	for i := 0; i < adaptiveMinSamples; i++ {
+		cb.adaptive.observe(time.Millisecond)
+	}
+	if got := cb.effectiveTimeout(); got != 50*time.Millisecond {
+		t.Errorf("adaptive timeout should be bounded by min, got `%s`", got)
+	}
+
+	for i := 0; i < adaptiveWindowSize; i++ {
+		cb.adaptive.observe(time.Second)
+	}
+	if got := cb.effectiveTimeout(); got != 200*time.Millisecond {
+		t.Errorf("adaptive timeout should be bounded by max, got `%s`", got)
+	}
+}
+
+func TestAdaptiveTimeoutObservesCalls(t *testing.T) {
+	cb := NewCircuitBreaker(
+		5, 1, 2*time.Second, time.Second,
+		WithAdaptiveTimeout(0.95, 2, time.Millisecond, time.Second),
+	)
+
+	for i := 0; i < adaptiveMinSamples; i++ {
+		cb.Call(func() (any, error) {
+			time.Sleep(10 * time.Millisecond)
+			return "OK", nil
+		})
+	}
+
+	// Some slack for scheduling delays
+	if got := cb.effectiveTimeout(); got < 20*time.Millisecond || got > 100*time.Millisecond {
+		t.Errorf("adaptive timeout should follow call latency, got `%s`", got)
+	}
+
+	// Call slower than the learned timeout is cut off
+	_, err := cb.Call(func() (any, error) {
+		time.Sleep(300 * time.Millisecond)
+		return "OK", nil
+	})
+	if err == nil {
+		t.Errorf("slow call should time out, got `nil`")
+	}
+}
@@ -0,0 +1,57 @@
+package circuitbreaker
+
+import "time"
+
+// WithErrorBudget opens the breaker once `maxFailures` failures happen within
+// a rolling `window`, regardless of the failure threshold. Failed probes
+// spend the budget as well. The open breaker attempts recovery once the
+// window drains below the budget, but not before the recovery time passes.
+func WithErrorBudget(maxFailures int, window time.Duration) Option {
+	return func(cb *CircuitBreaker) {
+		cb.budgetMaxFailures = maxFailures
+		cb.budgetWindow = window
+	}
+}
+
+// spendBudget records a failure which happened `at` against the budget.
+func (cb *CircuitBreaker) spendBudget(at time.Time) {
+	if cb.budgetMaxFailures <= 0 {
+		return
+	}
+
+	cb.budgetFailures = append(cb.budgetFailures, at)
+	cb.evictBudget()
+}
+
+// evictBudget forgets failures which left the budget window.
+func (cb *CircuitBreaker) evictBudget() {
+	cutoff := cb.clock.Now().Add(-cb.budgetWindow)
+	n := 0
+	for n < len(cb.budgetFailures) && !cb.budgetFailures[n].After(cutoff) {
+		n++
+	}
+	cb.budgetFailures = cb.budgetFailures[n:]
+}
+
+// budgetExhausted reports whether failures within the window reached the
+// budget.
+func (cb *CircuitBreaker) budgetExhausted() bool {
+	if cb.budgetMaxFailures <= 0 {
+		return false
+	}
+
+	cb.evictBudget()
+	return len(cb.budgetFailures) >= cb.budgetMaxFailures
+}
+
+// budgetDrainsIn returns time left until failures within the window drop
+// below the budget.
+func (cb *CircuitBreaker) budgetDrainsIn() time.Duration {
+	if !cb.budgetExhausted() {
+		return 0
+	}
+
+	// Failure which has to leave the window to get back below the budget
+	last := cb.budgetFailures[len(cb.budgetFailures)-cb.budgetMaxFailures]
+	return last.Add(cb.budgetWindow).Sub(cb.clock.Now())
+}
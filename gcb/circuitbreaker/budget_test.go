@@ -0,0 +1,87 @@
+package circuitbreaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestErrorBudgetExhausted(t *testing.T) {
+	clock := NewFakeClock()
+	cb := NewCircuitBreaker(100, 1, time.Second, time.Second,
+		WithClock(clock),
+		WithErrorBudget(5, time.Minute),
+	)
+
+	for i := 0; i < 4; i++ {
+		cb.Call(makeService(0, 1, 100))
+		clock.Advance(10 * time.Second)
+	}
+	if cb.State() != StateClosed {
+		t.Fatalf("breaker should stay closed within the budget, got `%s`", cb.State())
+	}
+
+	// Fifth failure within a minute exhausts the budget
+	cb.Call(makeService(0, 1, 100))
+	if cb.State() != StateOpen {
+		t.Fatalf("exhausted budget should open the breaker, got `%s`", cb.State())
+	}
+
+	// Recovery time passed, but the window still holds 5 failures
+	clock.Advance(2 * time.Second)
+	if _, err := cb.Call(makeService(0, 1, 0)); !errors.Is(err, ErrOpenState) {
+		t.Fatalf("breaker should stay open until the budget drains, got `%v`", err)
+	}
+	if d := cb.RetryAfter(); d != 18*time.Second {
+		t.Errorf("retry after should be time until the oldest failure leaves the window, got `%s`", d)
+	}
+
+	clock.Advance(18 * time.Second)
+	if !cb.AllowRequest() || cb.State() != StateHalfOpen {
+		t.Errorf("drained budget should make the breaker eligible for half open, got `%s`", cb.State())
+	}
+}
+
+func TestErrorBudgetRollingWindow(t *testing.T) {
+	clock := NewFakeClock()
+	cb := NewCircuitBreaker(100, 1, time.Second, time.Second,
+		WithClock(clock),
+		WithErrorBudget(3, time.Minute),
+	)
+
+	// Failures spread over more than the window never exhaust the budget
+	for i := 0; i < 10; i++ {
+		cb.Call(makeService(0, 1, 100))
+		clock.Advance(30 * time.Second)
+	}
+
+	if cb.State() != StateClosed {
+		t.Errorf("failures outside the window shouldn't count, got `%s`", cb.State())
+	}
+}
+
+func TestErrorBudgetFailedProbe(t *testing.T) {
+	clock := NewFakeClock()
+	cb := NewCircuitBreaker(100, 1, time.Second, time.Second,
+		WithClock(clock),
+		WithErrorBudget(2, time.Minute),
+	)
+
+	cb.Call(makeService(0, 1, 100))
+	clock.Advance(30 * time.Second)
+	cb.Call(makeService(0, 1, 100))
+
+	clock.Advance(31 * time.Second)
+	// Transition to half open state
+	cb.Call(makeService(0, 1, 0))
+	cb.Call(makeService(0, 1, 100))
+	if cb.State() != StateOpen {
+		t.Fatalf("failed probe should re-open the breaker, got `%s`", cb.State())
+	}
+
+	// Failed probe keeps the budget exhausted
+	clock.Advance(2 * time.Second)
+	if cb.AllowRequest() {
+		t.Error("failed probe should spend the budget")
+	}
+}
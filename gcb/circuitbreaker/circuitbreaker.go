@@ -2,164 +2,1307 @@ package circuitbreaker
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
+	"math/rand"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
-type circuitBreakerState = string
+// State of the circuit breaker
+type State int
+
 type operation = func() (any, error)
 
 const (
-	closed   = "closed"
-	open     = "open"
-	halfOpen = "half-open"
+	StateClosed State = iota
+	StateOpen
+	StateHalfOpen
+	// StateRecovering throttles traffic after `half-open` state, see
+	// `WithRecoveringState`
+	StateRecovering
+)
+
+var (
+	// ErrOpenState is returned when request is blocked by `open` state
+	ErrOpenState = errors.New("open state; request blocked")
+	// ErrTimeout is returned when operation didn't complete within the timeout
+	ErrTimeout = errors.New("request timed out")
+	// ErrTooManyRequests is returned when `half-open` probe is already running
+	ErrTooManyRequests = errors.New("half-open state; too many requests")
+	// ErrClosed is returned for calls made after the breaker was shut down
+	ErrClosed = errors.New("breaker is shut down")
+	// ErrBulkheadFull is returned when too many calls are already in flight
+	ErrBulkheadFull = errors.New("bulkhead full; too many concurrent requests")
+	// ErrPanic is returned when operation panicked, the panic is recovered
+	ErrPanic = errors.New("operation panicked")
+	// ErrThrottled is returned for calls shed in `recovering` state
+	ErrThrottled = errors.New("recovering state; request throttled")
+	// ErrNilOperation is returned for calls made with a `nil` operation
+	ErrNilOperation = errors.New("operation is nil")
 )
 
+// String returns human readable name of the state.
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	case StateRecovering:
+		return "recovering"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(s))
+	}
+}
+
+// MarshalJSON encodes the state as its name, e.g. `"half-open"`.
+func (s State) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
 type CircuitBreaker struct {
 	mu sync.Mutex
-	// Current state
-	state circuitBreakerState
+	// Source of time for state transitions
+	clock Clock
+	// Source of randomness, used under `mu`
+	rand *rand.Rand
+	// Name used in logs and errors to tell breakers apart
+	name string
+	// Disabled breaker passes all requests through without state logic
+	disabled bool
+	// Held breaker stays `open` until released or a health probe passes
+	held bool
+	// Shut down breaker rejects all requests
+	shutdown bool
+	// Number of operations currently executing
+	inFlight int
+	// Closed once no operations are executing, `nil` unless draining
+	idle chan struct{}
+	// Limit of operations executing at once, zero means no limit
+	maxConcurrent int
+	// Closed on shutdown to stop background goroutines
+	done chan struct{}
+	// Tracks background goroutines
+	wg sync.WaitGroup
+	// Current state, written under `mu` and read lock-free by `State`
+	state atomic.Int32
+	// Incremented on every transition to tell apart outcomes of stale calls
+	generation uint64
+	// Whether the `half-open` probe is in flight
+	probing bool
+	// Closed once the in-flight probe completes
+	probeReleased chan struct{}
+	// Number of callers waiting for the probe slot
+	halfOpenWaiters int
+	// Number of callers allowed to wait for the probe slot
+	halfOpenMaxWaiters int
+	// Time interval a caller may wait for the probe slot
+	halfOpenMaxWait time.Duration
+	// Runs in `half-open` state in place of user requests, `nil` unless enabled
+	probeFunc func() error
+	// Time interval between runs of `probeFunc`
+	probeInterval time.Duration
+	// Scheduled run of `probeFunc`, `nil` if none
+	probeTimer Timer
+	// Probability of admitting a `half-open` request, zero means single probe
+	halfOpenTrafficPercent float64
 
-	// Count of consecutive failures, zeroed out on success
+	// Weighted score of consecutive failures, zeroed out on success
 	failureCount int
+	// Number of consecutive timeouts, zeroed out on any other outcome
+	timeoutCount int
+	// Consecutive timeouts before transitioning to `open` state, zero means
+	// timeouts count only as failures
+	timeoutThreshold int
+	// Failures allowed within `budgetWindow`, zero means no error budget
+	budgetMaxFailures int
+	// Rolling window of the error budget
+	budgetWindow time.Duration
+	// Times of failures within the budget window, oldest first
+	budgetFailures []time.Time
 	// Time record of the last failure
 	lastFailureTime time.Time
+	// Failures contributing to `failureCount`, kept only when they expire
+	failures []failureRecord
+	// Age after which failures stop counting towards opening, zero keeps them
+	failureTTL time.Duration
+	// Failed probes since the breaker opened, counted once the breaker closes
+	probeFailures []failureRecord
+	// Whether failed probes count towards opening after the breaker closes
+	probeFailuresInWindow bool
 
 	// Count of successful requests in `half-open` state
 	successCount int
-	// Number of consecutive failures before transitioning to `open` state
+	// Failure score before transitioning to `open` state
 	failureThreshold int
+	// Score added to `failureCount` per failure, `nil` counts every failure as 1
+	failureWeight func(err error) int
+	// Matches failures which open the breaker regardless of the threshold
+	immediateOpen func(err error) bool
+	// Whether a result returned along with an error is discarded
+	dropResultOnError bool
+	// Invoked for every call shed by the breaker
+	onReject func(name string, reason RejectReason)
+	// Invoked after every call
+	observer func(ctx context.Context, name string, duration time.Duration, err error, outcome CallOutcome)
+	// Invoked after every transition
+	onStateChange func(ctx context.Context, name string, from, to State)
+	// Transitions made under the lock, reported once it's released
+	transitions []transition
+	// Context of the call holding the lock, `nil` for background work
+	callCtx context.Context
+	// Calls per second allowed, zero means no rate limit
+	rateLimit float64
+	// Maximal number of tokens in the rate limit bucket
+	rateBurst int
+	// Tokens left in the rate limit bucket
+	tokens float64
+	// Time the tokens were last refilled, zero while the bucket is untouched
+	tokensRefilled time.Time
+	// Decides faults failing operations, see `WithFaultInjector`
+	faultInjector func() error
+	// Whether faults are injected
+	injectingFaults atomic.Bool
+	// Traces calls, `nil` disables tracing
+	tracer trace.Tracer
+	// Runs operations of calls, see `WithExecutor`
+	executor Executor
+	// Decorators of operations, see `Use`
+	middlewares []Middleware
+	// Failure count firing `onWarning`, zero disables the warning
+	warningThreshold int
+	// Invoked when the failure count reaches `warningThreshold`
+	onWarning func(name string, failureCount int)
+	// Whether the warning fired since the failure count last dropped below
+	// `warningThreshold`
+	warned bool
+	// Failure counts of warnings fired under the lock, reported once it's
+	// released
+	warnings []int
+	// Clock time in nanoseconds until which calls are rejected without taking
+	// the lock, zero if they have to take it
+	rejectUntil atomic.Int64
+	// Successful probes added to `halfOpenThreshold` per failed recovery,
+	// zero keeps the threshold fixed
+	halfOpenStep int
+	// Upper bound of the escalated `halfOpenThreshold`
+	halfOpenMax int
+	// Successful probes currently added to `halfOpenThreshold`
+	halfOpenEscalation int
 
 	// Time interval before transitioning from `open` to `half-open` state
 	recoveryTime time.Duration
+	// Whether the transition to `half-open` is scheduled on the clock
+	autoHalfOpen bool
+	// Scheduled transition to `half-open` state, `nil` if none
+	recoveryTimer Timer
+	// Fraction of `recoveryTime` it's randomized by in each `open` cycle
+	recoveryJitter float64
+	// Recovery time of the current `open` cycle, jitter applied
+	cycleRecoveryTime time.Duration
 	// Count of successful requests for transitioning to `close` state
 	halfOpenThreshold int
+	// Count of requests made in `half-open` state
+	halfOpenAttempts int
+	// Minimal number of `half-open` requests to decide on recovery by rate,
+	// zero decides by `halfOpenThreshold` successes
+	halfOpenMinCalls int
+	// Success rate of `half-open` requests required to recover
+	halfOpenSuccessRate float64
+	// Whether `half-open` successes count cumulatively rather than consecutively
+	halfOpenCumulative bool
+	// Share of requests admitted in `recovering` state, zero skips the state
+	recoveringFraction float64
+	// Count of successful requests for transitioning from `recovering` to
+	// `closed` state
+	recoveringSuccesses int
+	// Latency above which a successful probe doesn't count, zero means no limit
+	halfOpenMaxLatency time.Duration
+	// Quiet period after which the next call resets the breaker, zero disables
+	idleReset time.Duration
+	// Time the last call was admitted, tracked with `idleReset` only
+	lastCallTime time.Time
+	// Whether a slow probe transitions back to `open` state
+	slowProbeReopens bool
 	// Time interval request has to complete successfully
 	timeout time.Duration
+	// Health check deciding when `open` state may transition to `half-open`
+	healthProbe func() bool
+	// Time interval between health checks
+	healthProbeInterval time.Duration
+	// Scheduled health check, `nil` if none
+	healthTimer Timer
+
+	// Number of times a failing operation is retried within a single call
+	maxRetries int
+	// Delay before the first retry, doubled after each attempt
+	retryBackoff time.Duration
+
+	// Outcomes of the most recent calls, `nil` unless enabled
+	history *callHistory
+	// Latency based timeout, `nil` unless enabled
+	adaptive *adaptiveTimeout
+	// Cumulative counts of calls
+	stats callStats
 }
 
+// Safe minimums the legacy constructor clamps settings to
+const (
+	minRecoveryTime = time.Millisecond
+	minTimeout      = time.Millisecond
+)
+
+// New creates a circuit breaker, rejecting thresholds below 1 and
+// non-positive durations with `ErrInvalidConfig`.
+func New(
+	failureThreshold, halfOpenThreshold int,
+	recoveryTime, timeout time.Duration,
+	opts ...Option,
+) (*CircuitBreaker, error) {
+	cfg := Config{
+		FailureThreshold:  failureThreshold,
+		HalfOpenThreshold: halfOpenThreshold,
+		RecoveryTime:      recoveryTime,
+		Timeout:           timeout,
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return NewCircuitBreaker(failureThreshold, halfOpenThreshold, recoveryTime, timeout, opts...), nil
+}
+
+// NewCircuitBreaker creates a circuit breaker. Thresholds below 1 and
+// non-positive durations are clamped to safe minimums with a logged warning,
+// use `New` to get an error instead.
 func NewCircuitBreaker(
 	failureThreshold, halfOpenThreshold int,
 	recoveryTime, timeout time.Duration,
+	opts ...Option,
 ) *CircuitBreaker {
-	return &CircuitBreaker{
-		state:             closed,
+	cb := &CircuitBreaker{
+		clock:             realClock{},
+		executor:          goroutineExecutor{},
+		rand:              rand.New(rand.NewSource(time.Now().UnixNano())),
+		name:              defaultName,
+		done:              make(chan struct{}),
 		failureThreshold:  failureThreshold,
 		recoveryTime:      recoveryTime,
 		halfOpenThreshold: halfOpenThreshold,
 		timeout:           timeout,
+		probeInterval:     defaultProbeInterval,
+	}
+
+	for _, opt := range opts {
+		opt(cb)
+	}
+	cb.clamp()
+	cb.stats.stateSince = cb.clock.Now()
+
+	return cb
+}
+
+// clamp raises settings below safe minimums to them.
+func (cb *CircuitBreaker) clamp() {
+	if cb.failureThreshold < 1 {
+		cb.warnClamped("failure threshold", cb.failureThreshold, 1)
+		cb.failureThreshold = 1
+	}
+	if cb.halfOpenThreshold < 1 {
+		cb.warnClamped("half-open threshold", cb.halfOpenThreshold, 1)
+		cb.halfOpenThreshold = 1
+	}
+	if cb.recoveryTime <= 0 {
+		cb.warnClamped("recovery time", cb.recoveryTime, minRecoveryTime)
+		cb.recoveryTime = minRecoveryTime
 	}
+	if cb.recoveringFraction > 0 && cb.recoveringSuccesses < 1 {
+		cb.warnClamped("recovering successes", cb.recoveringSuccesses, 1)
+		cb.recoveringSuccesses = 1
+	}
+	if cb.halfOpenStep > 0 && cb.halfOpenMax < cb.halfOpenThreshold {
+		cb.warnClamped("half-open threshold max", cb.halfOpenMax, cb.halfOpenThreshold)
+		cb.halfOpenMax = cb.halfOpenThreshold
+	}
+	if cb.rateLimit > 0 && cb.rateBurst < 1 {
+		cb.warnClamped("rate limit burst", cb.rateBurst, 1)
+		cb.rateBurst = 1
+	}
+	if cb.timeout <= 0 {
+		cb.warnClamped("timeout", cb.timeout, minTimeout)
+		cb.timeout = minTimeout
+	}
+}
+
+// warnClamped logs that `setting` was clamped from `value` to `clamped`.
+func (cb *CircuitBreaker) warnClamped(setting string, value, clamped any) {
+	slog.Warn("invalid setting clamped", "name", cb.name, "state", cb.loadState(),
+		"setting", setting, "value", value, "clamped", clamped)
 }
 
 func (cb *CircuitBreaker) Call(fn operation) (any, error) {
+	return cb.CallContext(context.Background(), fn)
+}
+
+// CallContext runs `fn` through the circuit breaker bound to `ctx`. When
+// `ctx` is canceled or its deadline passes the call returns `ctx.Err()`,
+// such errors are caused by the caller and are never counted as failures.
+func (cb *CircuitBreaker) CallContext(ctx context.Context, fn operation) (any, error) {
+	res, _, err := cb.call(ctx, "", fn)
+	return res, err
+}
+
+// CallDetailed runs `fn` through the circuit breaker and reports what the
+// breaker did with the call.
+func (cb *CircuitBreaker) CallDetailed(fn operation) (any, CallOutcome, error) {
+	return cb.call(context.Background(), "", fn)
+}
+
+// Do runs `fn` through the circuit breaker like `Call`, for operations which
+// have no result.
+func (cb *CircuitBreaker) Do(fn func() error) error {
+	var op operation
+	if fn != nil {
+		// Nil operation is refused by the breaker
+		op = func() (any, error) {
+			return nil, fn()
+		}
+	}
+
+	_, err := cb.Call(op)
+	return err
+}
+
+// call runs `fn` through the circuit breaker, all calls end up here. Outcome
+// is additionally accounted under `tag` unless it's empty.
+func (cb *CircuitBreaker) call(ctx context.Context, tag string, fn operation) (any, CallOutcome, error) {
+	if cb.tracer != nil {
+		return cb.tracedCall(ctx, tag, fn)
+	}
+
+	return cb.execute(ctx, tag, fn)
+}
+
+// execute runs `fn` through the circuit breaker untraced, see `call`.
+func (cb *CircuitBreaker) execute(ctx context.Context, tag string, fn operation) (any, CallOutcome, error) {
+	if fn == nil {
+		// Caller's mistake, the dependency is not at fault
+		err := cb.errorf("%w", ErrNilOperation)
+		cb.observe(ctx, 0, err, OutcomeNilOperation)
+		return nil, OutcomeNilOperation, err
+	}
+
+	t, outcome, err := cb.enter(ctx, tag)
+	if !t.run {
+		return nil, outcome, err
+	}
+	defer cb.leave()
+
+	// Operation runs without holding the lock, concurrent calls may proceed
+	res, elapsed, err := cb.runWithTimeout(ctx, t.timeout, chain(t.middlewares, cb.injectFaults(fn)))
+	outcome = executionOutcome(ctx, t, err)
+
+	res, err = cb.record(ctx, t, res, elapsed, err)
+	if err != nil && cb.dropResultOnError {
+		res = nil
+	}
+	cb.observe(ctx, elapsed, err, outcome)
+
+	return res, outcome, err
+}
+
+// enter admits a call, reporting the outcome of calls which don't get to run
+// their operation. Admitted call has to `leave` once its operation is
+// complete.
+func (cb *CircuitBreaker) enter(ctx context.Context, tag string) (ticket, CallOutcome, error) {
+	// Outage rejects most calls, they shouldn't contend for the lock
+	if err := cb.fastReject(ctx, tag); err != nil {
+		return ticket{}, OutcomeRejectedOpen, err
+	}
+
+	t, err := cb.admit(ctx, tag)
+	if isCanceled(ctx, err) {
+		cb.observe(ctx, 0, err, OutcomeCanceled)
+		return ticket{}, OutcomeCanceled, err
+	}
+	if err != nil {
+		outcome := rejectionOutcome(err)
+		cb.notifyReject(outcome)
+		cb.observe(ctx, 0, err, outcome)
+		return ticket{}, outcome, err
+	}
+
+	if !t.run {
+		cb.observe(ctx, 0, nil, OutcomeTransitioned)
+		return t, OutcomeTransitioned, nil
+	}
+
+	return t, 0, nil
+}
+
+// ticket is issued to an admitted call to account for its outcome.
+type ticket struct {
+	// Operation has to be executed
+	run bool
+	// Outcome is not counted, protection is disabled
+	bypass bool
+	// State the call was admitted in
+	state State
+	// Generation of the state the call was admitted in
+	generation uint64
+	// Timeout applied to the operation
+	timeout time.Duration
+	// Tag the outcome is accounted under
+	tag string
+	// Middlewares wrapping the operation
+	middlewares []Middleware
+}
+
+// admit decides whether a call may proceed, admitted call has to `leave`
+// once its operation is complete.
+func (cb *CircuitBreaker) admit(ctx context.Context, tag string) (ticket, error) {
 	cb.mu.Lock()
-	defer cb.mu.Unlock()
-
-	slog.Debug("call", "state", cb.state)
-
-	switch cb.state {
-	case closed:
-		// Healthy state, all requests are allowed
-		return cb.processClosedState(fn)
-	case open:
-		// Faulty state, all requests are blocked
-		return cb.processOpenState()
-	case halfOpen:
-		// Recovering state, allows limited requests
-		return cb.processHalfOpenState(fn)
+	defer cb.unlock()
+	cb.callCtx = ctx
+
+	slog.Debug("call", "name", cb.name, "state", cb.loadState())
+
+	t, err := cb.decide(ctx, false)
+	if isCanceled(ctx, err) {
+		// Caller gave up waiting, the call wasn't shed
+		return t, err
+	}
+	if err != nil {
+		cb.stats.add(tag, Counts{Rejections: 1})
+		return t, err
+	}
+
+	t.tag = tag
+	if t.run {
+		cb.inFlight++
+		t.middlewares = cb.middlewares
+	}
+
+	return t, nil
+}
+
+// leave marks the operation of an admitted call as complete.
+func (cb *CircuitBreaker) leave() {
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	cb.inFlight--
+	if cb.inFlight == 0 && cb.idle != nil {
+		close(cb.idle)
+		cb.idle = nil
+	}
+}
+
+// decide runs the admission checks for a call made right now. A `dry` run
+// records no call and takes neither a token nor the probe slot, nor does it
+// wait for the slot.
+func (cb *CircuitBreaker) decide(ctx context.Context, dry bool) (ticket, error) {
+	cb.resetIfIdle(dry)
+
+	if err := cb.limitRate(dry); err != nil {
+		return ticket{}, err
+	}
+
+	return cb.admitState(ctx, dry)
+}
+
+// admitState decides whether a call may proceed in the current state.
+func (cb *CircuitBreaker) admitState(ctx context.Context, dry bool) (ticket, error) {
+	// Deadline for waiting on the `half-open` probe slot, set on the first wait
+	var waitUntil time.Time
+
+	for {
+		if cb.shutdown {
+			return ticket{}, cb.reject(ErrClosed)
+		}
+
+		if cb.maxConcurrent > 0 && cb.inFlight >= cb.maxConcurrent {
+			return ticket{}, cb.reject(ErrBulkheadFull)
+		}
+
+		t := ticket{
+			run:        true,
+			state:      cb.loadState(),
+			generation: cb.generation,
+			timeout:    cb.effectiveTimeout(),
+		}
+
+		if cb.disabled {
+			// Protection is off, only the timeout is applied
+			t.bypass = true
+			return t, nil
+		}
+
+		switch cb.loadState() {
+		case StateClosed:
+			// Healthy state, all requests are allowed
+			return t, nil
+		case StateOpen:
+			// Faulty state, all requests are blocked
+			return ticket{}, cb.processOpenState()
+		case StateRecovering:
+			// Recovered state, a share of requests is allowed
+			if dry || cb.rand.Float64() < cb.recoveringFraction {
+				return t, nil
+			}
+			return ticket{}, cb.reject(ErrThrottled)
+		case StateHalfOpen:
+			if cb.probeFunc != nil {
+				// Probe function decides recovery, users wait for the outcome
+				return ticket{}, cb.reject(ErrTooManyRequests)
+			}
+
+			if cb.halfOpenTrafficPercent > 0 {
+				// Recovering state, a sample of requests is allowed
+				if dry || cb.rand.Float64() < cb.halfOpenTrafficPercent {
+					return t, nil
+				}
+				return ticket{}, cb.reject(ErrTooManyRequests)
+			}
+
+			// Recovering state, a single probe is allowed at a time
+			if !cb.probing {
+				if !dry {
+					cb.acquireProbe()
+				}
+				return t, nil
+			}
+
+			if waitUntil.IsZero() {
+				if cb.halfOpenWaiters >= cb.halfOpenMaxWaiters {
+					return ticket{}, cb.reject(ErrTooManyRequests)
+				}
+				if dry {
+					// Call would wait for the probe slot
+					return t, nil
+				}
+				waitUntil = cb.clock.Now().Add(cb.halfOpenMaxWait)
+			}
+
+			// Probe slot may free up, state is re-evaluated after the wait
+			if ok, err := cb.waitForProbe(ctx, waitUntil); err != nil {
+				return ticket{}, err
+			} else if !ok {
+				return ticket{}, cb.reject(ErrTooManyRequests)
+			}
+		default:
+			return ticket{}, cb.errorf("unknown state `%s`", cb.loadState())
+		}
+	}
+}
+
+// AllowRequest reports whether a call made right now would be admitted,
+// without running anything or taking a token or the probe slot. It runs the
+// same checks as a call, so an idle breaker is reset and one in `open` state
+// transitions to `half-open` if the recovery time passed. Calls admitted by
+// chance, e.g. in `recovering` state, are reported as allowed. The answer is
+// advisory, the state may change before the actual call.
+func (cb *CircuitBreaker) AllowRequest() bool {
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	_, err := cb.decide(context.Background(), true)
+	return err == nil
+}
+
+// acquireProbe takes the `half-open` probe slot.
+func (cb *CircuitBreaker) acquireProbe() {
+	cb.probing = true
+	cb.probeReleased = make(chan struct{})
+}
+
+// releaseProbe frees the `half-open` probe slot and wakes up its waiters.
+func (cb *CircuitBreaker) releaseProbe() {
+	if !cb.probing {
+		return
+	}
+
+	cb.probing = false
+	close(cb.probeReleased)
+}
+
+// waitForProbe blocks until the in-flight probe completes or `until` passes
+// on the breaker clock, reporting whether the probe completed. The wait ends
+// early with `ctx.Err()` once `ctx` is done. Must be called with the lock
+// held, the lock is released for the duration of the wait.
+func (cb *CircuitBreaker) waitForProbe(ctx context.Context, until time.Time) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	cb.halfOpenWaiters++
+	released := cb.probeReleased
+	expired := make(chan struct{})
+	timer := cb.clock.AfterFunc(until.Sub(cb.clock.Now()), func() {
+		close(expired)
+	})
+	// Other calls take the lock meanwhile, context of this one is restored
+	callCtx := cb.callCtx
+	cb.mu.Unlock()
+
+	var ok bool
+	var err error
+	select {
+	case <-released:
+		ok = true
+	case <-expired:
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-cb.done:
+		// Shutdown is reported once the state is re-evaluated
+		ok = true
+	}
+	timer.Stop()
+
+	cb.mu.Lock()
+	cb.callCtx = callCtx
+	cb.halfOpenWaiters--
+
+	return ok, err
+}
+
+// record accounts for the outcome of a call admitted with `t`.
+func (cb *CircuitBreaker) record(
+	ctx context.Context,
+	t ticket,
+	res any,
+	elapsed time.Duration,
+	err error,
+) (any, error) {
+	cb.mu.Lock()
+	defer cb.unlock()
+	cb.callCtx = ctx
+
+	if cb.history != nil {
+		cb.history.add(CallRecord{At: cb.clock.Now().Add(-elapsed), Duration: elapsed, Err: err})
+	}
+	if cb.adaptive != nil && err == nil {
+		cb.adaptive.observe(elapsed)
+	}
+	if err == nil {
+		cb.stats.observeLatency(elapsed)
+	}
+	counts := executionCounts(ctx, err)
+	cb.stats.add(t.tag, counts)
+	if counts.Failures > 0 {
+		cb.stats.addFailure(failureReason(err))
+	}
+
+	if t.bypass {
+		return res, err
+	}
+
+	if t.generation != cb.generation {
+		// State changed while the operation was running, outcome is stale
+		return res, err
+	}
+
+	switch t.state {
+	case StateClosed:
+		return cb.processClosedState(ctx, res, err)
+	case StateHalfOpen:
+		cb.releaseProbe()
+		return cb.processHalfOpenState(ctx, res, elapsed, err)
+	case StateRecovering:
+		return cb.processRecoveringState(ctx, res, err)
 	default:
-		return nil, errors.New(fmt.Sprintf("unknown state `%s`", cb.state))
+		return res, err
+	}
+}
+
+// Disable turns off protection, all requests pass through the breaker without
+// being counted and the state never changes until `Enable` is called.
+func (cb *CircuitBreaker) Disable() {
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	slog.Info("protection disabled", "name", cb.name, "state", cb.loadState())
+	cb.disabled = true
+}
+
+// Enable turns protection back on starting from a clean `closed` state.
+func (cb *CircuitBreaker) Enable() {
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	if !cb.disabled {
+		return
+	}
+
+	slog.Info("protection enabled", "name", cb.name, "state", cb.loadState())
+	cb.disabled = false
+	cb.reset()
+}
+
+// reset clears counters of the circuit breaker, transitioning it into
+// `closed` state unless it's already there.
+func (cb *CircuitBreaker) reset() {
+	cb.successCount = 0
+	cb.lastFailureTime = time.Time{}
+	cb.budgetFailures = nil
+	cb.halfOpenEscalation = 0
+	if cb.loadState() != StateClosed {
+		cb.resetCircuit()
+	} else {
+		cb.failureCount = 0
+		cb.failures = nil
+		cb.timeoutCount = 0
 	}
 }
 
-func (cb *CircuitBreaker) processClosedState(fn operation) (any, error) {
-	// Attempt to run operation with `cb.timeout` timeout
-	res, err := cb.runWithTimeout(fn)
+// Hold opens the circuit breaker and keeps it open regardless of the recovery
+// time until `Release` is called. With a health probe configured a passing
+// probe releases the hold as well.
+func (cb *CircuitBreaker) Hold() {
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	slog.Info("holding open", "name", cb.name, "state", cb.loadState())
+	cb.held = true
+	cb.cancelRecovery()
+	if cb.loadState() != StateOpen {
+		cb.lastFailureTime = cb.clock.Now()
+		cb.setState(StateOpen)
+	}
+}
+
+// Release lets the held circuit breaker resume normal transitions, it moves
+// to `half-open` state once the recovery time since the last failure passes.
+func (cb *CircuitBreaker) Release() {
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	if !cb.held {
+		return
+	}
+
+	slog.Info("released", "name", cb.name, "state", cb.loadState())
+	cb.held = false
+	cb.scheduleRecovery()
+}
+
+// Close shuts down the circuit breaker, stopping its background goroutines.
+// Calls made afterwards return `ErrClosed`, while calls already in flight
+// complete normally. It is safe to call Close multiple times.
+func (cb *CircuitBreaker) Close() error {
+	cb.mu.Lock()
+	cb.stop()
+	cb.unlock()
+
+	// Background goroutines may need the lock to exit
+	cb.wg.Wait()
+
+	return nil
+}
+
+// DrainAndClose shuts down the circuit breaker like `Close` and waits for
+// calls in flight to complete. Returns `ctx.Err()` if they don't complete
+// before `ctx` is done.
+func (cb *CircuitBreaker) DrainAndClose(ctx context.Context) error {
+	cb.mu.Lock()
+	cb.stop()
+
+	var idle chan struct{}
+	if cb.inFlight > 0 {
+		if cb.idle == nil {
+			cb.idle = make(chan struct{})
+		}
+		idle = cb.idle
+	}
+	cb.unlock()
+
+	cb.wg.Wait()
+
+	if idle == nil {
+		return nil
+	}
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// stop marks the circuit breaker as shut down and signals background
+// goroutines to exit, must be called with the lock held.
+func (cb *CircuitBreaker) stop() {
+	if cb.shutdown {
+		return
+	}
+
+	slog.Info("shutting down", "name", cb.name, "state", cb.loadState())
+	cb.shutdown = true
+	close(cb.done)
+	cb.cancelRecovery()
+	cb.cancelTracked(&cb.healthTimer)
+	cb.cancelTracked(&cb.probeTimer)
+}
+
+// cancelTracked cancels `*timer`, scheduled along with `cb.wg.Add`, and
+// releases its share of the wait group unless it already ran.
+func (cb *CircuitBreaker) cancelTracked(timer *Timer) {
+	if *timer == nil {
+		return
+	}
+
+	if (*timer).Stop() {
+		cb.wg.Done()
+	}
+	*timer = nil
+}
+
+// Result carries the outcome of an asynchronous call
+type Result struct {
+	Value any
+	Err   error
+}
+
+// CallAsync runs `fn` through the circuit breaker in a separate goroutine.
+// Exactly one Result is delivered on the returned channel, after which the
+// channel is closed.
+func (cb *CircuitBreaker) CallAsync(fn operation) <-chan Result {
+	resChan := make(chan Result, 1)
+
+	go func() {
+		defer close(resChan)
+		res, err := cb.Call(fn)
+		resChan <- Result{res, err}
+	}()
+
+	return resChan
+}
+
+// CallMany runs a batch of operations through the circuit breaker with at
+// most `maxConcurrency` of them in flight, values below 1 mean no limit.
+// Once the breaker opens remaining operations are rejected instead of being
+// attempted. Results are returned in the order of `fns`.
+func (cb *CircuitBreaker) CallMany(fns []operation, maxConcurrency int) []Result {
+	if maxConcurrency < 1 {
+		maxConcurrency = max(1, len(fns))
+	}
+
+	results := make([]Result, len(fns))
+	sem := make(chan struct{}, maxConcurrency)
+
+	var wg sync.WaitGroup
+	for i, fn := range fns {
+		sem <- struct{}{}
+		wg.Add(1)
+
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			res, err := cb.Call(fn)
+			results[i] = Result{res, err}
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
+
+// processClosedState accounts for the outcome of an operation in `closed` state.
+func (cb *CircuitBreaker) processClosedState(ctx context.Context, res any, err error) (any, error) {
+	if inconclusive(ctx, err) {
+		// Caller gave up on the request or it never ran, dependency is not
+		// at fault
+		return nil, err
+	}
+
+	// Only an unbroken run of timeouts counts towards the timeout threshold
+	if errors.Is(err, ErrTimeout) {
+		cb.timeoutCount++
+	} else {
+		cb.timeoutCount = 0
+	}
+
 	if err != nil {
 		// Operation is timing out, start state transition checks
-		cb.failureCount++
-		cb.lastFailureTime = time.Now()
+		cb.lastFailureTime = cb.clock.Now()
+		cb.addFailure(cb.lastFailureTime, cb.weigh(err))
+		cb.spendBudget(cb.lastFailureTime)
 
-		slog.Debug("request failed", "count", cb.failureCount, "state", "closed")
+		slog.Debug("request failed", "name", cb.name, "count", cb.failureCount,
+			"timeouts", cb.timeoutCount, "state", cb.loadState())
 
 		// If we got more failures than threshold allows transition to open state.
-		if cb.failureCount >= cb.failureThreshold {
-			slog.Info("state transitioning to `open`", "state", "closed")
-			cb.state = open
+		// Some failures are severe enough to open right away.
+		if cb.failureCount >= cb.failureThreshold || cb.opensImmediately(err) ||
+			cb.timedOutRepeatedly() || cb.budgetExhausted() {
+			cb.setState(StateOpen)
 		}
 
-		return nil, err
+		// Result is passed through, failed operations may still carry details
+		return res, err
 	}
 
+	// Only consecutive failures count towards opening
+	cb.failureCount = 0
+	cb.failures = nil
+
 	return res, nil
 }
 
+// timedOutRepeatedly reports whether consecutive timeouts reached the
+// timeout threshold.
+func (cb *CircuitBreaker) timedOutRepeatedly() bool {
+	return cb.timeoutThreshold > 0 && cb.timeoutCount >= cb.timeoutThreshold
+}
+
+// weigh returns the score `err` adds towards opening the breaker.
+func (cb *CircuitBreaker) weigh(err error) int {
+	if cb.failureWeight == nil {
+		return 1
+	}
+
+	return cb.failureWeight(err)
+}
+
+// failureRecord is a failure which expires after `failureTTL`.
+type failureRecord struct {
+	at     time.Time
+	weight int
+}
+
+// addFailure adds a failure of `weight` which happened `at` to the score.
+func (cb *CircuitBreaker) addFailure(at time.Time, weight int) {
+	cb.failureCount += weight
+
+	if cb.failureTTL > 0 {
+		cb.failures = append(cb.failures, failureRecord{at, weight})
+		cb.evictFailures()
+	}
+}
+
+// evictFailures removes failures older than `failureTTL` from the score.
+func (cb *CircuitBreaker) evictFailures() {
+	if cb.failureTTL <= 0 {
+		return
+	}
+
+	cutoff := cb.clock.Now().Add(-cb.failureTTL)
+	n := 0
+	for n < len(cb.failures) && !cb.failures[n].at.After(cutoff) {
+		cb.failureCount -= cb.failures[n].weight
+		n++
+	}
+	cb.failures = cb.failures[n:]
+}
+
+// opensImmediately reports whether `err` opens the breaker regardless of the
+// failure threshold.
+func (cb *CircuitBreaker) opensImmediately(err error) bool {
+	return cb.immediateOpen != nil && cb.immediateOpen(err)
+}
+
+// recoverCircuit transitions the circuit breaker from `half-open` to `closed`
+// state, carrying over probe failures when they are counted. With the
+// `recovering` state configured it's passed through on the way.
+func (cb *CircuitBreaker) recoverCircuit() {
+	if cb.recoveringFraction > 0 && cb.loadState() == StateHalfOpen {
+		cb.toRecovering()
+		return
+	}
+
+	probeFailures := cb.probeFailures
+	cb.resetCircuit()
+	cb.deescalate()
+
+	for _, f := range probeFailures {
+		cb.addFailure(f.at, f.weight)
+	}
+}
+
 func (cb *CircuitBreaker) resetCircuit() {
 	cb.failureCount = 0
-	cb.state = closed
+	cb.failures = nil
+	cb.timeoutCount = 0
+	cb.probeFailures = nil
+	cb.successCount = 0
+	cb.halfOpenAttempts = 0
+	cb.setState(StateClosed)
+}
+
+// loadState returns the current state without taking the lock.
+func (cb *CircuitBreaker) loadState() State {
+	return State(cb.state.Load())
+}
+
+// setState transitions the circuit breaker into `to` state.
+func (cb *CircuitBreaker) setState(to State) {
+	from := cb.loadState()
+	cb.stats.leaveState(from, cb.clock.Now())
+	slog.Info(fmt.Sprintf("state transitioning to `%s`", to), "name", cb.name, "state", from)
+	cb.state.Store(int32(to))
+	cb.queueTransition(from, to)
+	cb.generation++
+	cb.releaseProbe()
+
+	if to == StateOpen {
+		cb.cycleRecoveryTime = cb.jitteredRecoveryTime()
+	}
+
+	cb.cancelTracked(&cb.healthTimer)
+	if to == StateOpen && cb.healthProbe != nil && !cb.shutdown {
+		cb.scheduleHealthProbe(cb.generation)
+	}
+
+	cb.cancelTracked(&cb.probeTimer)
+	if to == StateHalfOpen && cb.probeFunc != nil && !cb.shutdown {
+		// First probe runs right away, the recovery time has passed
+		cb.scheduleProbeFunc(cb.generation, 0)
+	}
+
+	cb.scheduleRecovery()
+}
+
+// jitteredRecoveryTime randomizes `recoveryTime` by up to `recoveryJitter`
+// of it in either direction.
+func (cb *CircuitBreaker) jitteredRecoveryTime() time.Duration {
+	if cb.recoveryJitter <= 0 {
+		return cb.recoveryTime
+	}
+
+	spread := cb.recoveryJitter * (2*cb.rand.Float64() - 1)
+	return cb.recoveryTime + time.Duration(spread*float64(cb.recoveryTime))
+}
+
+// errorf formats an error prefixed with the breaker name. Use `%w` verb to
+// keep sentinel errors matchable with `errors.Is`.
+func (cb *CircuitBreaker) errorf(format string, args ...any) error {
+	return fmt.Errorf("breaker %q: "+format, append([]any{cb.name}, args...)...)
 }
 
 // processOpenState blocks all requests
-func (cb *CircuitBreaker) processOpenState() (any, error) {
+func (cb *CircuitBreaker) processOpenState() error {
 	// If time threshold since the last failure passed transition state to half open.
-	if time.Since(cb.lastFailureTime) > cb.recoveryTime {
-		slog.Info("state transitioning to `half-open`", "state", "open")
-		cb.state = halfOpen
-		cb.failureCount = 0
-		return nil, nil
+	// With health probe configured the probe decides when to transition.
+	// Held breaker doesn't transition on its own.
+	if cb.recoveryDue() {
+		cb.toHalfOpen()
+		return nil
 	}
 
 	// Not enough time passed since the last failure.
-	return nil, errors.New("open state; request blocked")
+	return cb.reject(ErrOpenState)
+}
+
+// recoveryDue reports whether the breaker in `open` state may transition to
+// `half-open` on the next call.
+func (cb *CircuitBreaker) recoveryDue() bool {
+	return cb.healthProbe == nil && !cb.held &&
+		cb.clock.Now().Sub(cb.lastFailureTime) > cb.cycleRecoveryTime && !cb.budgetExhausted()
 }
 
-// processHalfOpenState attempts to execute the operation and verifies eligibility
-// for recovery.
-func (cb *CircuitBreaker) processHalfOpenState(fn operation) (any, error) {
-	res, err := cb.runWithTimeout(fn)
+// toHalfOpen transitions the circuit breaker into `half-open` state.
+func (cb *CircuitBreaker) toHalfOpen() {
+	cb.setState(StateHalfOpen)
+	cb.failureCount = 0
+	cb.failures = nil
+	cb.timeoutCount = 0
+	cb.successCount = 0
+	cb.halfOpenAttempts = 0
+}
+
+// processHalfOpenState accounts for the outcome of the probe and verifies
+// eligibility for recovery.
+func (cb *CircuitBreaker) processHalfOpenState(
+	ctx context.Context,
+	res any,
+	elapsed time.Duration,
+	err error,
+) (any, error) {
+	if inconclusive(ctx, err) {
+		// Caller gave up on the request or it never ran, probe is inconclusive
+		return nil, err
+	}
+
+	// Successful but slow probe doesn't prove recovery
+	slow := err == nil && cb.halfOpenMaxLatency > 0 && elapsed > cb.halfOpenMaxLatency
+	if slow {
+		slog.Debug("slow probe", "name", cb.name, "state", cb.loadState(), "elapsed", elapsed)
+
+		if cb.slowProbeReopens {
+			cb.reopen()
+			return res, nil
+		}
+	}
+
+	if err != nil {
+		cb.spendBudget(cb.clock.Now())
+	}
+
+	if err != nil && cb.probeFailuresInWindow {
+		// Kept aside until the breaker closes
+		cb.probeFailures = append(cb.probeFailures, failureRecord{cb.clock.Now(), cb.weigh(err)})
+	}
+
+	if cb.halfOpenMinCalls > 0 {
+		return cb.processHalfOpenRate(res, err, !slow)
+	}
+
+	if cb.halfOpenCumulative {
+		return cb.processHalfOpenCumulative(res, err, !slow)
+	}
+
 	if err != nil {
 		// Operation is still failing, transition back to `open` state
-		slog.Info("state transitioning to `open`", "state", "half-open")
-		cb.state = open
-		cb.lastFailureTime = time.Now()
-		return nil, err
+		cb.reopen()
+		return res, err
+	}
+
+	if slow {
+		return res, nil
 	}
 
 	// Recovering is starting
-	slog.Debug("successfull operation", "state", "half-open")
+	slog.Debug("successfull operation", "name", cb.name, "state", cb.loadState())
 	cb.successCount++
 
-	if cb.successCount >= cb.halfOpenThreshold {
-		slog.Info("state transtioning to `closed`", "state", "half-open")
-		cb.resetCircuit()
+	if cb.successCount >= cb.effectiveHalfOpenThreshold() {
+		cb.recoverCircuit()
 	}
 
 	return res, nil
 }
 
-func (cb *CircuitBreaker) runWithTimeout(fn operation) (any, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), cb.timeout)
-	defer cancel()
+// processHalfOpenRate accounts for the outcome of the probe when recovery is
+// decided by the success rate over a number of probes. Probes which returned
+// no error but aren't `fast` enough don't count as successes.
+func (cb *CircuitBreaker) processHalfOpenRate(res any, err error, fast bool) (any, error) {
+	cb.halfOpenAttempts++
+	if err == nil && fast {
+		cb.successCount++
+	}
 
-	type Message struct {
-		result any
-		err    error
+	// Best rate achievable if all remaining probes succeed
+	left := max(0, cb.halfOpenMinCalls-cb.halfOpenAttempts)
+	best := float64(cb.successCount+left) / float64(cb.halfOpenAttempts+left)
+
+	slog.Debug("probe completed", "name", cb.name, "state", cb.loadState(),
+		"attempts", cb.halfOpenAttempts, "successes", cb.successCount)
+
+	switch {
+	case best < cb.halfOpenSuccessRate:
+		// Required rate can't be met, transition back to `open` state
+		cb.reopen()
+	case left == 0:
+		cb.recoverCircuit()
 	}
 
-	resChan := make(chan Message, 1)
+	return res, err
+}
 
-	go func() {
-		res, err := fn()
-		resChan <- Message{res, err}
-	}()
+// processHalfOpenCumulative accounts for the outcome of the probe when
+// successes count cumulatively, tolerating up to `halfOpenThreshold` failed
+// probes. Probes which returned no error but aren't `fast` enough count as
+// failures.
+func (cb *CircuitBreaker) processHalfOpenCumulative(res any, err error, fast bool) (any, error) {
+	cb.halfOpenAttempts++
+	if err == nil && fast {
+		cb.successCount++
+	}
 
-	select {
-	case <-ctx.Done():
-		return nil, errors.New("request timed out")
-	case res := <-resChan:
-		return res.result, res.err
+	slog.Debug("probe completed", "name", cb.name, "state", cb.loadState(),
+		"attempts", cb.halfOpenAttempts, "successes", cb.successCount)
+
+	switch {
+	case cb.successCount >= cb.effectiveHalfOpenThreshold():
+		cb.recoverCircuit()
+	case cb.halfOpenAttempts-cb.successCount > cb.halfOpenThreshold:
+		// Too many failures, transition back to `open` state
+		cb.reopen()
+	}
+
+	return res, err
+}
+
+// reopen transitions the circuit breaker from `half-open` back to `open` state.
+func (cb *CircuitBreaker) reopen() {
+	cb.escalate()
+	cb.lastFailureTime = cb.clock.Now()
+	cb.setState(StateOpen)
+}
+
+// runWithTimeout runs `fn` until it completes, `timeout` elapses or `ctx` is
+// done, and reports how long it took. The call never waits past the deadline
+// of `ctx`. Breaker timeout is reported as `ErrTimeout`, while the caller's
+// cancellation or deadline is reported as `ctx.Err()`.
+func (cb *CircuitBreaker) runWithTimeout(
+	ctx context.Context,
+	timeout time.Duration,
+	fn operation,
+) (any, time.Duration, error) {
+	timeoutCtx, cancel := withCallTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	res, err := cb.executor.Execute(timeoutCtx, func() (res any, err error) {
+		defer func() {
+			// Panic in the operation counts as its failure
+			if r := recover(); r != nil {
+				res, err = nil, cb.errorf("%w: %v", ErrPanic, r)
+			}
+		}()
+
+		return cb.retry(timeoutCtx, fn)
+	})
+	elapsed := time.Since(start)
+
+	switch {
+	case timeoutCtx.Err() == nil || !errors.Is(err, timeoutCtx.Err()):
+		return res, elapsed, err
+	case ctx.Err() != nil:
+		return nil, elapsed, ctx.Err()
+	default:
+		return nil, elapsed, cb.errorf("%w", ErrTimeout)
 	}
 }
+
+// withCallTimeout derives the context of a single call which ends at the
+// earlier of `timeout` and the deadline of `ctx`. When the caller's deadline
+// comes first no timer of its own is set, so expiry is always attributed to
+// the caller. Expiry of `timeout` has `ErrTimeout` as its cause.
+func withCallTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= timeout {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeoutCause(ctx, timeout, ErrTimeout)
+}
+
+// isCanceled reports whether `err` is caused by the caller's own `ctx` being
+// canceled or exceeding its deadline.
+func isCanceled(ctx context.Context, err error) bool {
+	return err != nil && ctx.Err() != nil && errors.Is(err, ctx.Err())
+}
+
+// inconclusive reports whether `err` tells nothing about the health of the
+// dependency, i.e. the caller gave up or the operation never started.
+func inconclusive(ctx context.Context, err error) bool {
+	return isCanceled(ctx, err) || notStarted(err)
+}
+
+// notStarted reports whether `err` tells the executor never started the
+// operation.
+func notStarted(err error) bool {
+	return errors.Is(err, ErrExecutorSaturated) || errors.Is(err, ErrExecutorClosed)
+}
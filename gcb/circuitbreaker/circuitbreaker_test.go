@@ -1,8 +1,14 @@
 package circuitbreaker
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"math/rand"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -37,6 +43,56 @@ func TestCallNeverFailing(t *testing.T) {
 	}
 }
 
+func TestSuccessClearsFailures(t *testing.T) {
+	cb := NewCircuitBreaker(2, 1, time.Minute, time.Second)
+
+	callOutcomes(cb, false, true, false)
+	if cb.State() != StateClosed {
+		t.Errorf("only consecutive failures should open the breaker, got `%s`", cb.State())
+	}
+
+	callOutcomes(cb, false)
+	if cb.State() != StateOpen {
+		t.Errorf("consecutive failures should open the breaker, got `%s`", cb.State())
+	}
+}
+
+func TestCallNilOperation(t *testing.T) {
+	cb := NewCircuitBreaker(1, 1, time.Minute, time.Second)
+
+	res, outcome, err := cb.CallDetailed(nil)
+	if res != nil || outcome != OutcomeNilOperation || !errors.Is(err, ErrNilOperation) {
+		t.Errorf("nil operation should be refused, got `%v`, `%s`, `%v`", res, outcome, err)
+	}
+
+	if _, err := CallWithResult[int](cb, nil); !errors.Is(err, ErrNilOperation) {
+		t.Errorf("nil typed operation should be refused, got `%v`", err)
+	}
+
+	if err := cb.Do(nil); !errors.Is(err, ErrNilOperation) {
+		t.Errorf("nil operation without result should be refused, got `%v`", err)
+	}
+
+	if cb.State() != StateClosed || cb.Stats().Counts != (Counts{}) {
+		t.Errorf("nil operation shouldn't be accounted, got `%s` with `%+v`", cb.State(), cb.Stats().Counts)
+	}
+}
+
+func TestCallPanic(t *testing.T) {
+	cb := NewCircuitBreaker(1, 1, time.Minute, time.Second)
+
+	res, err := cb.Call(func() (any, error) {
+		panic("nil map")
+	})
+	if res != nil || !errors.Is(err, ErrPanic) || !strings.Contains(err.Error(), "nil map") {
+		t.Errorf("panic should be recovered as `ErrPanic`, got `%v`, `%v`", res, err)
+	}
+
+	if cb.State() != StateOpen {
+		t.Errorf("panic should count as failure, got `%s`", cb.State())
+	}
+}
+
 func TestOpenState(t *testing.T) {
 	cb := NewCircuitBreaker(1, 2, 2*time.Second, 2*time.Second)
 	// Always failing service
@@ -47,8 +103,8 @@ func TestOpenState(t *testing.T) {
 		t.Errorf("service should return error, got `nil`")
 	}
 
-	if cb.state != open {
-		t.Errorf("circuit breaker should open on failure, got `%s`", cb.state)
+	if cb.State() != StateOpen {
+		t.Errorf("circuit breaker should open on failure, got `%s`", cb.State())
 	}
 }
 
@@ -65,8 +121,8 @@ func TestHalfOpenState(t *testing.T) {
 	// Transition to half open state
 	cb.Call(neverFailing)
 
-	if cb.state != halfOpen {
-		t.Errorf("state should move to half open, got `%s`", cb.state)
+	if cb.State() != StateHalfOpen {
+		t.Errorf("state should move to half open, got `%s`", cb.State())
 	}
 }
 
@@ -89,7 +145,838 @@ func TestRecovery(t *testing.T) {
 	// Transition to closed state
 	cb.Call(neverFailing)
 
-	if cb.state != closed {
-		t.Errorf("state should move to closed, got `%s`", cb.state)
+	if cb.State() != StateClosed {
+		t.Errorf("state should move to closed, got `%s`", cb.State())
+	}
+}
+
+// receiveOnce reads a single result from `ch` and asserts the channel is closed
+// afterwards.
+func receiveOnce(t *testing.T, ch <-chan Result) Result {
+	t.Helper()
+
+	res, ok := <-ch
+	if !ok {
+		t.Fatalf("channel closed before delivering a result")
+	}
+
+	if _, ok := <-ch; ok {
+		t.Errorf("channel should be closed after delivering a single result")
+	}
+
+	return res
+}
+
+func TestCallAsync(t *testing.T) {
+	cb := NewCircuitBreaker(2, 2, 2*time.Second, 2*time.Second)
+	// Never failing service
+	s := makeService(20, 200, 0)
+
+	res := receiveOnce(t, cb.CallAsync(s))
+	if res.Err != nil {
+		t.Errorf("service shouldn't fail, got `%s` error", res.Err)
+	}
+
+	if res.Value != "OK" {
+		t.Errorf("service always returns `OK`, got `%s`", res.Value)
+	}
+}
+
+func TestCallAsyncOpenState(t *testing.T) {
+	cb := NewCircuitBreaker(1, 1, 2*time.Second, 2*time.Second)
+	// Always failing service
+	s := makeService(20, 200, 100)
+
+	res := receiveOnce(t, cb.CallAsync(s))
+	if res.Err == nil {
+		t.Errorf("service should return error, got `nil`")
+	}
+
+	// Breaker is open now, call is rejected without running the service
+	res = receiveOnce(t, cb.CallAsync(s))
+	if !errors.Is(res.Err, ErrOpenState) {
+		t.Errorf("call should be blocked in open state, got `%v`", res.Err)
+	}
+}
+
+func TestCallAsyncTimeout(t *testing.T) {
+	cb := NewCircuitBreaker(2, 2, 2*time.Second, 10*time.Millisecond)
+	// Service slower than the timeout
+	s := makeService(100, 200, 0)
+
+	res := receiveOnce(t, cb.CallAsync(s))
+	if !errors.Is(res.Err, ErrTimeout) {
+		t.Errorf("call should time out, got `%v`", res.Err)
+	}
+}
+
+func TestCallContextCallerCancel(t *testing.T) {
+	cb := NewCircuitBreaker(1, 1, 2*time.Second, 2*time.Second)
+	// Never failing but slow service
+	s := makeService(200, 300, 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := cb.CallContext(ctx, s)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("call should return caller's context error, got `%v`", err)
+	}
+
+	if cb.State() != StateClosed {
+		t.Errorf("caller cancellation shouldn't open the breaker, got `%s`", cb.State())
+	}
+
+	if cb.failureCount != 0 {
+		t.Errorf("caller cancellation shouldn't count as failure, got `%d`", cb.failureCount)
+	}
+}
+
+func TestCallContextOperationCanceled(t *testing.T) {
+	cb := NewCircuitBreaker(1, 1, 2*time.Second, 2*time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// Operation respects the caller's context and reports its error
+	_, err := cb.CallContext(ctx, func() (any, error) {
+		return nil, fmt.Errorf("fetch: %w", ctx.Err())
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("call should return canceled error, got `%v`", err)
+	}
+
+	if cb.State() != StateClosed {
+		t.Errorf("caller cancellation shouldn't open the breaker, got `%s`", cb.State())
+	}
+}
+
+func TestCallContextBreakerTimeout(t *testing.T) {
+	cb := NewCircuitBreaker(1, 1, 2*time.Second, 20*time.Millisecond)
+	// Never failing but slow service
+	s := makeService(200, 300, 0)
+
+	_, err := cb.CallContext(context.Background(), s)
+	if !errors.Is(err, ErrTimeout) {
+		t.Errorf("call should time out, got `%v`", err)
+	}
+
+	if cb.State() != StateOpen {
+		t.Errorf("breaker timeout should open the breaker, got `%s`", cb.State())
+	}
+}
+
+func TestDisabledNeverOpens(t *testing.T) {
+	cb := NewCircuitBreaker(1, 1, 2*time.Second, 2*time.Second)
+	cb.Disable()
+	// Always failing service
+	s := makeService(20, 50, 100)
+
+	for i := 0; i < 5; i++ {
+		_, err := cb.Call(s)
+		if err == nil || errors.Is(err, ErrOpenState) {
+			t.Errorf("disabled breaker should pass through service error, got `%v`", err)
+		}
+	}
+
+	if cb.State() != StateClosed {
+		t.Errorf("disabled breaker should never open, got `%s`", cb.State())
+	}
+
+	if cb.failureCount != 0 {
+		t.Errorf("disabled breaker shouldn't count failures, got `%d`", cb.failureCount)
+	}
+}
+
+func TestEnableResumesFromClosed(t *testing.T) {
+	cb := NewCircuitBreaker(1, 1, 2*time.Second, 2*time.Second)
+	// Always failing service
+	s := makeService(20, 50, 100)
+
+	// Open the breaker, then disable and re-enable protection
+	cb.Call(s)
+	cb.Disable()
+	cb.Enable()
+
+	if cb.State() != StateClosed || cb.failureCount != 0 {
+		t.Errorf("enabled breaker should be clean closed, got `%s` with `%d` failures", cb.State(), cb.failureCount)
+	}
+
+	// Normal behavior resumes
+	cb.Call(s)
+	if cb.State() != StateOpen {
+		t.Errorf("enabled breaker should open on failure, got `%s`", cb.State())
+	}
+}
+
+func TestCallManyTripsPartway(t *testing.T) {
+	cb := NewCircuitBreaker(2, 1, 2*time.Second, 2*time.Second)
+	failure := errors.New("service failed")
+
+	var executed []int
+	op := func(i int, err error) operation {
+		return func() (any, error) {
+			executed = append(executed, i)
+			if err != nil {
+				return nil, err
+			}
+			return i, nil
+		}
+	}
+
+	fns := []operation{op(0, nil), op(1, failure), op(2, failure), op(3, nil), op(4, nil)}
+	results := cb.CallMany(fns, 1)
+
+	if len(results) != len(fns) {
+		t.Fatalf("expected `%d` results, got `%d`", len(fns), len(results))
+	}
+
+	if results[0].Value != 0 || results[0].Err != nil {
+		t.Errorf("first operation should succeed, got `%+v`", results[0])
+	}
+
+	for _, i := range []int{1, 2} {
+		if !errors.Is(results[i].Err, failure) {
+			t.Errorf("operation %d should fail with service error, got `%v`", i, results[i].Err)
+		}
+	}
+
+	for _, i := range []int{3, 4} {
+		if !errors.Is(results[i].Err, ErrOpenState) {
+			t.Errorf("operation %d should be rejected by open breaker, got `%v`", i, results[i].Err)
+		}
+	}
+
+	if len(executed) != 3 {
+		t.Errorf("only operations before opening should run, got `%v`", executed)
+	}
+}
+
+func TestCallManyConcurrencyLimit(t *testing.T) {
+	cb := NewCircuitBreaker(10, 1, 2*time.Second, 2*time.Second)
+
+	var mu sync.Mutex
+	inFlight, peak := 0, 0
+	fn := func() (any, error) {
+		mu.Lock()
+		inFlight++
+		peak = max(peak, inFlight)
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		return "OK", nil
+	}
+
+	fns := make([]operation, 10)
+	for i := range fns {
+		fns[i] = fn
+	}
+
+	for i, res := range cb.CallMany(fns, 3) {
+		if res.Err != nil || res.Value != "OK" {
+			t.Errorf("operation %d should succeed, got `%+v`", i, res)
+		}
+	}
+
+	if peak > 3 {
+		t.Errorf("at most 3 operations should run at once, got `%d`", peak)
+	}
+}
+
+func TestHalfOpenSingleProbe(t *testing.T) {
+	cb := NewCircuitBreaker(1, 1, 50*time.Millisecond, 2*time.Second)
+
+	// Transition to open state, then to half open state
+	cb.Call(makeService(20, 50, 100))
+	time.Sleep(100 * time.Millisecond)
+	cb.Call(makeService(20, 50, 0))
+
+	if cb.State() != StateHalfOpen {
+		t.Fatalf("state should move to half open, got `%s`", cb.State())
+	}
+
+	var hits atomic.Int32
+	release := make(chan struct{})
+	probe := func() (any, error) {
+		hits.Add(1)
+		<-release
+		return "OK", nil
+	}
+
+	const callers = 20
+	start := make(chan struct{})
+	errs := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		go func() {
+			<-start
+			_, err := cb.Call(probe)
+			errs <- err
+		}()
+	}
+	close(start)
+
+	// Every caller except the probe is rejected while the probe is blocked
+	for i := 0; i < callers-1; i++ {
+		select {
+		case err := <-errs:
+			if !errors.Is(err, ErrTooManyRequests) {
+				t.Errorf("concurrent caller should be rejected, got `%v`", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("concurrent callers should be rejected immediately")
+		}
+	}
+
+	close(release)
+	if err := <-errs; err != nil {
+		t.Errorf("probe should succeed, got `%v`", err)
+	}
+
+	if n := hits.Load(); n != 1 {
+		t.Errorf("exactly one caller should hit the service, got `%d`", n)
+	}
+
+	if cb.State() != StateClosed {
+		t.Errorf("successful probe should close the breaker, got `%s`", cb.State())
+	}
+}
+
+func TestCloseRejectsCalls(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+
+	cb := NewCircuitBreaker(1, 1, 2*time.Second, 2*time.Second)
+	cb.Call(makeService(20, 50, 0))
+
+	if err := cb.Close(); err != nil {
+		t.Errorf("close should succeed, got `%s`", err)
+	}
+
+	_, err := cb.Call(makeService(20, 50, 0))
+	if !errors.Is(err, ErrClosed) {
+		t.Errorf("call after close should fail, got `%v`", err)
+	}
+
+	// Give exited goroutines a moment to be accounted for
+	time.Sleep(50 * time.Millisecond)
+	if n := runtime.NumGoroutine(); n > baseline {
+		t.Errorf("goroutine count should return to `%d`, got `%d`", baseline, n)
+	}
+}
+
+func TestCloseIdempotentWithInFlightCalls(t *testing.T) {
+	cb := NewCircuitBreaker(1, 1, 2*time.Second, 2*time.Second)
+
+	inFlight := cb.CallAsync(makeService(50, 100, 0))
+	// Let the call get admitted before closing
+	time.Sleep(10 * time.Millisecond)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := cb.Close(); err != nil {
+				t.Errorf("close should succeed, got `%s`", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if res := <-inFlight; res.Err != nil || res.Value != "OK" {
+		t.Errorf("in flight call should complete, got `%+v`", res)
+	}
+}
+
+// makeHalfOpen trips `cb`, which has to open on a single failure, and waits
+// for it to move into half open state.
+func makeHalfOpen(t *testing.T, cb *CircuitBreaker) {
+	t.Helper()
+
+	cb.Call(func() (any, error) {
+		return nil, errors.New("service failed")
+	})
+	time.Sleep(cb.recoveryTime + 10*time.Millisecond)
+	// Transition to half open state
+	cb.Call(func() (any, error) {
+		return "OK", nil
+	})
+
+	if cb.State() != StateHalfOpen {
+		t.Fatalf("state should move to half open, got `%s`", cb.State())
+	}
+}
+
+func TestDrainAndCloseWaitsForInFlight(t *testing.T) {
+	cb := NewCircuitBreaker(1, 1, 2*time.Second, 2*time.Second)
+
+	inFlight := []<-chan Result{
+		cb.CallAsync(sleepingService(50 * time.Millisecond)),
+		cb.CallAsync(sleepingService(80 * time.Millisecond)),
+	}
+	// Let the calls get admitted before draining
+	time.Sleep(10 * time.Millisecond)
+
+	drained := make(chan error, 1)
+	go func() {
+		drained <- cb.DrainAndClose(context.Background())
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	if _, err := cb.Call(sleepingService(time.Millisecond)); !errors.Is(err, ErrClosed) {
+		t.Errorf("new calls should be rejected while draining, got `%v`", err)
+	}
+
+	select {
+	case err := <-drained:
+		t.Fatalf("drain shouldn't return before in flight calls complete, got `%v`", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	if err := <-drained; err != nil {
+		t.Errorf("drain should succeed, got `%s`", err)
+	}
+
+	for _, ch := range inFlight {
+		select {
+		case res := <-ch:
+			if res.Err != nil {
+				t.Errorf("in flight call should complete, got `%v`", res.Err)
+			}
+		default:
+			t.Errorf("in flight call should be complete once drained")
+		}
+	}
+}
+
+func TestDrainAndCloseDeadline(t *testing.T) {
+	cb := NewCircuitBreaker(1, 1, 2*time.Second, 2*time.Second)
+	cb.CallAsync(sleepingService(200 * time.Millisecond))
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := cb.DrainAndClose(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("drain should fail past the deadline, got `%v`", err)
+	}
+}
+
+func TestDrainAndCloseIdle(t *testing.T) {
+	cb := NewCircuitBreaker(1, 1, 2*time.Second, 2*time.Second)
+
+	if err := cb.DrainAndClose(context.Background()); err != nil {
+		t.Errorf("drain without in flight calls should succeed, got `%s`", err)
+	}
+}
+
+func TestWithCallTimeout(t *testing.T) {
+	callCtx, cancel := withCallTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if deadline, ok := callCtx.Deadline(); !ok || time.Until(deadline) > time.Second {
+		t.Errorf("call without caller deadline should end at the timeout, got `%s`", deadline)
+	}
+
+	ctx, cancelCaller := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancelCaller()
+	callerDeadline, _ := ctx.Deadline()
+
+	callCtx, cancel = withCallTimeout(ctx, time.Second)
+	defer cancel()
+
+	if deadline, _ := callCtx.Deadline(); !deadline.Equal(callerDeadline) {
+		t.Errorf("call should end at the caller's deadline `%s`, got `%s`", callerDeadline, deadline)
+	}
+
+	callCtx, cancel = withCallTimeout(ctx, time.Millisecond)
+	defer cancel()
+
+	if deadline, _ := callCtx.Deadline(); !deadline.Before(callerDeadline) {
+		t.Errorf("call should end at the shorter timeout, got `%s`", deadline)
+	}
+}
+
+func TestCallContextEarlierCallerDeadline(t *testing.T) {
+	cb := NewCircuitBreaker(1, 1, 2*time.Second, time.Second, WithHistory(1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := cb.CallContext(ctx, sleepingService(500*time.Millisecond))
+	elapsed := time.Since(start)
+
+	if !errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrTimeout) {
+		t.Errorf("call should fail with caller's deadline, got `%v`", err)
+	}
+
+	if elapsed < 30*time.Millisecond || elapsed > 200*time.Millisecond {
+		t.Errorf("call should return at the caller's deadline, took `%s`", elapsed)
+	}
+
+	if d := cb.History()[0].Duration; d > 200*time.Millisecond {
+		t.Errorf("recorded duration should end at the caller's deadline, got `%s`", d)
+	}
+
+	if cb.State() != StateClosed {
+		t.Errorf("caller's deadline shouldn't open the breaker, got `%s`", cb.State())
+	}
+}
+
+func TestCallContextEarlierBreakerTimeout(t *testing.T) {
+	cb := NewCircuitBreaker(1, 1, 2*time.Second, 30*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_, err := cb.CallContext(ctx, sleepingService(500*time.Millisecond))
+	if !errors.Is(err, ErrTimeout) {
+		t.Errorf("call should fail with breaker timeout, got `%v`", err)
+	}
+
+	if cb.State() != StateOpen {
+		t.Errorf("breaker timeout should open the breaker, got `%s`", cb.State())
+	}
+}
+
+func TestDo(t *testing.T) {
+	clock := NewFakeClock()
+	cb := NewCircuitBreaker(2, 1, time.Second, time.Second, WithClock(clock))
+
+	published := 0
+	failing := true
+	publish := func() error {
+		if failing {
+			return errors.New("broker unavailable")
+		}
+		published++
+		return nil
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := cb.Do(publish); err == nil {
+			t.Fatal("failing operation should return its error")
+		}
+	}
+	if cb.State() != StateOpen {
+		t.Fatalf("failures should open the breaker, got `%s`", cb.State())
+	}
+
+	if err := cb.Do(publish); !errors.Is(err, ErrOpenState) {
+		t.Errorf("open breaker should reject the operation, got `%v`", err)
+	}
+
+	failing = false
+	clock.Advance(2 * time.Second)
+	// Transition to half open state
+	cb.Do(publish)
+	if err := cb.Do(publish); err != nil {
+		t.Errorf("recovered operation shouldn't fail, got `%v`", err)
+	}
+
+	if cb.State() != StateClosed || published != 1 {
+		t.Errorf("breaker should close after the probe, got `%s` with %d published", cb.State(), published)
+	}
+}
+
+func TestHoldKeepsOpen(t *testing.T) {
+	clock := NewFakeClock()
+	cb := NewCircuitBreaker(1, 1, time.Second, time.Second, WithClock(clock))
+
+	cb.Call(makeService(0, 1, 100))
+	cb.Hold()
+
+	for i := 0; i < 10; i++ {
+		clock.Advance(time.Minute)
+		if _, err := cb.Call(makeService(0, 1, 0)); !errors.Is(err, ErrOpenState) {
+			t.Fatalf("held breaker should stay open, got `%v`", err)
+		}
+	}
+
+	cb.Release()
+	// Transition to half open state
+	cb.Call(makeService(0, 1, 0))
+	cb.Call(makeService(0, 1, 0))
+	if cb.State() != StateClosed {
+		t.Errorf("released breaker should recover, got `%s`", cb.State())
+	}
+}
+
+func TestHoldOpensClosedBreaker(t *testing.T) {
+	clock := NewFakeClock()
+	cb := NewCircuitBreaker(5, 1, time.Second, time.Second, WithClock(clock))
+
+	cb.Hold()
+	if _, err := cb.Call(makeService(0, 1, 0)); !errors.Is(err, ErrOpenState) {
+		t.Fatalf("held breaker should reject calls, got `%v`", err)
+	}
+
+	cb.Release()
+	if _, err := cb.Call(makeService(0, 1, 0)); !errors.Is(err, ErrOpenState) {
+		t.Errorf("released breaker should wait for the recovery time, got `%v`", err)
+	}
+
+	clock.Advance(2 * time.Second)
+	cb.Call(makeService(0, 1, 0))
+	if cb.State() != StateHalfOpen {
+		t.Errorf("released breaker should move to half open after the recovery time, got `%s`", cb.State())
+	}
+}
+
+func TestHoldReleasedByHealthProbe(t *testing.T) {
+	clock := NewFakeClock()
+	var healthy atomic.Bool
+	cb := NewCircuitBreaker(1, 1, time.Second, time.Second,
+		WithClock(clock),
+		WithHealthProbe(healthy.Load, time.Millisecond),
+	)
+	defer cb.Close()
+
+	cb.Hold()
+	clock.Advance(time.Minute)
+	if cb.State() != StateOpen {
+		t.Fatal("held breaker shouldn't recover while the probe fails")
+	}
+
+	healthy.Store(true)
+	clock.Advance(time.Millisecond)
+	if cb.State() != StateHalfOpen {
+		t.Errorf("passing probe should release the held breaker, got `%s`", cb.State())
+	}
+}
+
+// unavailableService fails with a body carrying a retry hint
+func unavailableService() (any, error) {
+	return "retry in 30s", errors.New("service unavailable")
+}
+
+func TestFailureResultPassedThrough(t *testing.T) {
+	clock := NewFakeClock()
+	cb := NewCircuitBreaker(1, 1, time.Second, time.Second, WithClock(clock))
+
+	res, err := cb.Call(unavailableService)
+	if err == nil || res != "retry in 30s" {
+		t.Errorf("failure in closed state should pass the result through, got `%v`, `%v`", res, err)
+	}
+
+	clock.Advance(2 * time.Second)
+	// Transition to half open state
+	cb.Call(unavailableService)
+
+	res, err = cb.Call(unavailableService)
+	if err == nil || res != "retry in 30s" {
+		t.Errorf("failed probe should pass the result through, got `%v`, `%v`", res, err)
+	}
+	if cb.State() != StateOpen {
+		t.Errorf("failed probe should re-open the breaker, got `%s`", cb.State())
+	}
+}
+
+func TestFailureResultPassedThroughSuccessRate(t *testing.T) {
+	clock := NewFakeClock()
+	cb := NewCircuitBreaker(1, 1, time.Second, time.Second, WithClock(clock), WithHalfOpenSuccessRate(3, 0.5))
+
+	cb.Call(unavailableService)
+	clock.Advance(2 * time.Second)
+	// Transition to half open state
+	cb.Call(unavailableService)
+
+	res, err := cb.Call(unavailableService)
+	if err == nil || res != "retry in 30s" {
+		t.Errorf("failed probe should pass the result through, got `%v`, `%v`", res, err)
+	}
+}
+
+func TestAllowRequest(t *testing.T) {
+	clock := NewFakeClock()
+	cb := NewCircuitBreaker(1, 2, time.Second, time.Second, WithClock(clock))
+
+	if !cb.AllowRequest() {
+		t.Error("closed breaker should allow requests")
+	}
+
+	cb.Call(makeService(0, 1, 100))
+	if cb.AllowRequest() {
+		t.Error("open breaker shouldn't allow requests before the recovery time")
+	}
+
+	clock.Advance(2 * time.Second)
+	if !cb.AllowRequest() {
+		t.Error("open breaker should allow requests after the recovery time")
+	}
+	if cb.State() != StateHalfOpen {
+		t.Fatalf("breaker should move to half open, got `%s`", cb.State())
+	}
+
+	release := make(chan struct{})
+	probe := cb.CallAsync(blockingProbe(release))
+	// Let the probe take the slot
+	time.Sleep(10 * time.Millisecond)
+
+	if cb.AllowRequest() {
+		t.Error("half open breaker shouldn't allow requests while the probe is in flight")
+	}
+
+	close(release)
+	<-probe
+	if !cb.AllowRequest() {
+		t.Error("half open breaker should allow requests once the probe completes")
+	}
+
+	// Asking doesn't take the probe slot
+	if _, outcome, _ := cb.CallDetailed(makeService(0, 1, 0)); outcome != OutcomeProbe {
+		t.Errorf("call should be admitted as a probe, got `%s`", outcome)
+	}
+	if cb.State() != StateClosed {
+		t.Errorf("successful probes should close the breaker, got `%s`", cb.State())
+	}
+
+	cb.Close()
+	if cb.AllowRequest() {
+		t.Error("shut down breaker shouldn't allow requests")
+	}
+}
+
+func TestAllowRequestBulkhead(t *testing.T) {
+	cb := NewCircuitBreaker(1, 1, time.Second, time.Second, WithMaxConcurrent(1))
+
+	release := make(chan struct{})
+	res := cb.CallAsync(blockingProbe(release))
+	time.Sleep(10 * time.Millisecond)
+
+	if cb.AllowRequest() {
+		t.Error("full bulkhead shouldn't allow requests")
+	}
+
+	close(release)
+	<-res
+	if !cb.AllowRequest() {
+		t.Error("bulkhead with free capacity should allow requests")
+	}
+}
+
+func TestNewValidation(t *testing.T) {
+	tests := []struct {
+		name                                string
+		failureThreshold, halfOpenThreshold int
+		recoveryTime, timeout               time.Duration
+	}{
+		{"zero failure threshold", 0, 1, time.Second, time.Second},
+		{"negative failure threshold", -1, 1, time.Second, time.Second},
+		{"zero half-open threshold", 1, 0, time.Second, time.Second},
+		{"zero recovery time", 1, 1, 0, time.Second},
+		{"negative recovery time", 1, 1, -time.Second, time.Second},
+		{"zero timeout", 1, 1, time.Second, 0},
+		{"negative timeout", 1, 1, time.Second, -time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cb, err := New(tt.failureThreshold, tt.halfOpenThreshold, tt.recoveryTime, tt.timeout)
+			if !errors.Is(err, ErrInvalidConfig) || cb != nil {
+				t.Errorf("invalid settings should be rejected, got `%v`", err)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	cb, err := New(2, 1, time.Second, time.Second, WithName("payments"))
+	if err != nil {
+		t.Fatalf("valid settings should create a breaker, got `%v`", err)
+	}
+
+	if cb.Name() != "payments" || cb.Config().FailureThreshold != 2 {
+		t.Errorf("breaker should use the given settings, got `%+v`", cb.Config())
+	}
+}
+
+func TestNewCircuitBreakerClamps(t *testing.T) {
+	logs := captureLogs(t)
+
+	cb := NewCircuitBreaker(0, -1, 0, -time.Second)
+
+	got := cb.Config()
+	if got.FailureThreshold != 1 || got.HalfOpenThreshold != 1 ||
+		got.RecoveryTime != minRecoveryTime || got.Timeout != minTimeout {
+		t.Errorf("invalid settings should be clamped to safe minimums, got `%+v`", got)
+	}
+
+	if n := strings.Count(logs.String(), "invalid setting clamped"); n != 4 {
+		t.Errorf("each clamped setting should be logged, got %d warnings", n)
+	}
+
+	// Settings are clamped, no failure means no opening
+	cb.Call(makeService(0, 1, 0))
+	if cb.State() != StateClosed {
+		t.Errorf("clamped breaker shouldn't open without failures, got `%s`", cb.State())
+	}
+}
+
+func TestNewCircuitBreakerValidNotClamped(t *testing.T) {
+	logs := captureLogs(t)
+
+	NewCircuitBreaker(3, 2, time.Second, time.Second)
+	if strings.Contains(logs.String(), "clamped") {
+		t.Errorf("valid settings shouldn't be clamped, got `%s`", logs)
+	}
+}
+
+func TestCallOpenFastPath(t *testing.T) {
+	clock := NewFakeClock()
+	cb := NewCircuitBreaker(1, 1, time.Minute, time.Second, WithClock(clock))
+	cb.Call(makeService(0, 1, 100))
+
+	op := func() (any, error) {
+		t.Errorf("operation shouldn't run while open")
+		return nil, nil
+	}
+	// Only the returned error is allocated
+	allocs := testing.AllocsPerRun(100, func() {
+		cb.Call(op)
+	})
+	if allocs > 1 {
+		t.Errorf("rejection should allocate at most the error, got %v allocations", allocs)
+	}
+
+	_, err := cb.Call(op)
+	var breakerErr *BreakerError
+	if !errors.As(err, &breakerErr) || !errors.Is(err, ErrOpenState) || breakerErr.RetryAfter != time.Minute {
+		t.Errorf("rejection should report the time left, got `%v`", err)
+	}
+	if got := cb.Stats().Rejections; got != 102 {
+		t.Errorf("all rejections should be counted, got %d", got)
+	}
+
+	clock.Advance(time.Minute + time.Millisecond)
+	if _, outcome, _ := cb.CallDetailed(makeService(0, 1, 0)); outcome != OutcomeTransitioned {
+		t.Errorf("call after the recovery time should transition, got `%s`", outcome)
+	}
+}
+
+func BenchmarkCallOpen(b *testing.B) {
+	cb := NewCircuitBreaker(1, 1, time.Hour, time.Second)
+	cb.Call(makeService(0, 1, 100))
+	op := func() (any, error) { return "OK", nil }
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			cb.Call(op)
+		}
+	})
+}
+
+func BenchmarkCallClosed(b *testing.B) {
+	cb := NewCircuitBreaker(1, 1, time.Hour, time.Second)
+	op := func() (any, error) { return "OK", nil }
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		cb.Call(op)
 	}
 }
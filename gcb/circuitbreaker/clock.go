@@ -0,0 +1,145 @@
+package circuitbreaker
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Clock is the source of time for state transitions of the circuit breaker.
+// Operation timeouts always run on the wall clock, except for permits of
+// `Try`, which run no operation and expire on this clock.
+type Clock interface {
+	Now() time.Time
+	// AfterFunc calls `f` in its own goroutine once `d` passes
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a call scheduled with `Clock.AfterFunc`.
+type Timer interface {
+	// Stop cancels the call, reporting whether it was still pending
+	Stop() bool
+}
+
+// realClock tells the wall clock time
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// WithClock sets the source of time used for state transitions.
+func WithClock(clock Clock) Option {
+	return func(cb *CircuitBreaker) {
+		cb.clock = clock
+	}
+}
+
+// WithRand sets the source of randomness used for sampling requests, e.g. by
+// `WithHalfOpenTrafficPercent`. It's used under the breaker lock, so `r`
+// shouldn't be shared with other breakers.
+func WithRand(r *rand.Rand) Option {
+	return func(cb *CircuitBreaker) {
+		cb.rand = r
+	}
+}
+
+// FakeClock is a manually advanced clock for driving the breaker through
+// time deterministically, e.g. in tests or with `Simulate`. Scheduled calls
+// run synchronously within `Advance` once due.
+type FakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// fakeTimer is a call scheduled on the fake clock.
+type fakeTimer struct {
+	clock *FakeClock
+	at    time.Time
+	f     func()
+}
+
+// NewFakeClock returns a fake clock standing still at a fixed point in time.
+func NewFakeClock() *FakeClock {
+	return &FakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &fakeTimer{clock: c, at: c.now.Add(d), f: f}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// Advance moves the clock forward by `d`, running calls which became due.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+
+	// Due calls may schedule further calls, which may be due as well
+	for {
+		t := c.nextDue()
+		if t == nil {
+			return
+		}
+		t.f()
+	}
+}
+
+// nextDue removes and returns the earliest due timer, `nil` if none is due.
+func (c *FakeClock) nextDue() *fakeTimer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	due := -1
+	for i, t := range c.timers {
+		if !t.at.After(c.now) && (due < 0 || t.at.Before(c.timers[due].at)) {
+			due = i
+		}
+	}
+	if due < 0 {
+		return nil
+	}
+
+	t := c.timers[due]
+	c.timers = append(c.timers[:due], c.timers[due+1:]...)
+	return t
+}
+
+// Pending returns the number of scheduled calls which haven't run yet.
+func (c *FakeClock) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.timers)
+}
+
+func (t *fakeTimer) Stop() bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, pending := range c.timers {
+		if pending == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,38 @@
+package circuitbreaker
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestFakeClockAdvance(t *testing.T) {
+	clock := NewFakeClock()
+	start := clock.Now()
+
+	var fired []int
+	clock.AfterFunc(2*time.Second, func() { fired = append(fired, 2) })
+	clock.AfterFunc(time.Second, func() {
+		fired = append(fired, 1)
+		// Scheduled by a due call relative to the advanced time
+		clock.AfterFunc(time.Second, func() { fired = append(fired, 3) })
+	})
+	stopped := clock.AfterFunc(time.Second, func() { fired = append(fired, 4) })
+
+	if !stopped.Stop() || stopped.Stop() {
+		t.Errorf("stop should report whether the call was still pending")
+	}
+
+	clock.Advance(1500 * time.Millisecond)
+	if !slices.Equal(fired, []int{1}) || clock.Pending() != 2 {
+		t.Errorf("advance should run due calls in order, got `%v`, %d pending", fired, clock.Pending())
+	}
+	if got := clock.Now().Sub(start); got != 1500*time.Millisecond {
+		t.Errorf("advance should move the clock forward, got `%s`", got)
+	}
+
+	clock.Advance(time.Second)
+	if !slices.Equal(fired, []int{1, 2, 3}) || clock.Pending() != 0 {
+		t.Errorf("advance should run remaining calls, got `%v`, %d pending", fired, clock.Pending())
+	}
+}
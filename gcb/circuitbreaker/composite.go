@@ -0,0 +1,114 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+)
+
+// AggregationMode decides how states of child breakers add up to the state
+// of a composite breaker.
+type AggregationMode int
+
+const (
+	// AllOpen reports the composite open only while all children are open
+	AllOpen AggregationMode = iota
+	// AnyOpen reports the composite open while any child is open
+	AnyOpen
+)
+
+// CompositeBreaker fronts a single logical dependency with multiple circuit
+// breakers, e.g. one per region.
+type CompositeBreaker struct {
+	mode     AggregationMode
+	children []*CircuitBreaker
+}
+
+// NewCompositeBreaker creates a composite of `children`, which are tried in
+// the given order.
+func NewCompositeBreaker(mode AggregationMode, children ...*CircuitBreaker) *CompositeBreaker {
+	return &CompositeBreaker{
+		mode:     mode,
+		children: children,
+	}
+}
+
+// State returns the aggregate state of the children. In `AnyOpen` mode it's
+// the most degraded state of any child, `open` before `half-open` before
+// `recovering`. In `AllOpen` mode the composite is closed while any child
+// is, otherwise it's the least degraded state of the children.
+func (c *CompositeBreaker) State() State {
+	var open, halfOpen, recovering int
+	for _, child := range c.children {
+		switch child.State() {
+		case StateOpen:
+			open++
+		case StateHalfOpen:
+			halfOpen++
+		case StateRecovering:
+			recovering++
+		}
+	}
+
+	switch c.mode {
+	case AnyOpen:
+		if open > 0 {
+			return StateOpen
+		}
+		if halfOpen > 0 {
+			return StateHalfOpen
+		}
+		if recovering > 0 {
+			return StateRecovering
+		}
+		return StateClosed
+	default:
+		if len(c.children) == 0 || open+halfOpen+recovering < len(c.children) {
+			return StateClosed
+		}
+		if recovering > 0 {
+			return StateRecovering
+		}
+		if halfOpen > 0 {
+			return StateHalfOpen
+		}
+		return StateOpen
+	}
+}
+
+// Call runs `fn` through the first child breaker which accepts it. In
+// `AnyOpen` mode all calls are rejected while any child is open.
+func (c *CompositeBreaker) Call(fn operation) (any, error) {
+	if c.mode == AnyOpen {
+		for _, child := range c.children {
+			if child.State() == StateOpen {
+				return nil, child.rejectOpen()
+			}
+		}
+	}
+
+	err := error(&BreakerError{Name: "composite", State: c.State(), Err: ErrOpenState})
+	for _, child := range c.children {
+		res, outcome, childErr := child.call(context.Background(), "", fn)
+
+		var be *BreakerError
+		if outcome == OutcomeTransitioned || errors.As(childErr, &be) {
+			// Child didn't run the operation, try the next one
+			if childErr != nil {
+				err = childErr
+			}
+			continue
+		}
+
+		return res, childErr
+	}
+
+	return nil, err
+}
+
+// rejectOpen builds the error for a call rejected by `open` state.
+func (cb *CircuitBreaker) rejectOpen() error {
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	return cb.reject(ErrOpenState)
+}
@@ -0,0 +1,154 @@
+package circuitbreaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// regionBreakers creates named breakers, opening those marked as failing.
+func regionBreakers(failing map[string]bool, names ...string) []*CircuitBreaker {
+	breakers := make([]*CircuitBreaker, len(names))
+	for i, name := range names {
+		breakers[i] = NewCircuitBreaker(1, 1, time.Minute, time.Second, WithName(name), WithHistory(10))
+		if failing[name] {
+			callOutcomes(breakers[i], false)
+		}
+	}
+
+	return breakers
+}
+
+// regionService returns `value` on success
+func regionService(value string) operation {
+	return func() (any, error) {
+		return value, nil
+	}
+}
+
+func TestCompositeAllOpenState(t *testing.T) {
+	cases := []struct {
+		failing map[string]bool
+		want    State
+	}{
+		{map[string]bool{}, StateClosed},
+		{map[string]bool{"eu": true}, StateClosed},
+		{map[string]bool{"eu": true, "us": true}, StateOpen},
+	}
+
+	for _, c := range cases {
+		composite := NewCompositeBreaker(AllOpen, regionBreakers(c.failing, "eu", "us")...)
+		if got := composite.State(); got != c.want {
+			t.Errorf("composite with failing `%v` should be `%s`, got `%s`", c.failing, c.want, got)
+		}
+	}
+}
+
+func TestCompositeAnyOpenState(t *testing.T) {
+	cases := []struct {
+		failing map[string]bool
+		want    State
+	}{
+		{map[string]bool{}, StateClosed},
+		{map[string]bool{"eu": true}, StateOpen},
+		{map[string]bool{"eu": true, "us": true}, StateOpen},
+	}
+
+	for _, c := range cases {
+		composite := NewCompositeBreaker(AnyOpen, regionBreakers(c.failing, "eu", "us")...)
+		if got := composite.State(); got != c.want {
+			t.Errorf("composite with failing `%v` should be `%s`, got `%s`", c.failing, c.want, got)
+		}
+	}
+}
+
+func TestCompositeHalfOpenState(t *testing.T) {
+	breakers := regionBreakers(map[string]bool{"eu": true, "us": true}, "eu", "us")
+	breakers[0].mu.Lock()
+	breakers[0].toHalfOpen()
+	breakers[0].mu.Unlock()
+
+	if got := NewCompositeBreaker(AllOpen, breakers...).State(); got != StateHalfOpen {
+		t.Errorf("all open composite with recovering child should be half open, got `%s`", got)
+	}
+
+	if got := NewCompositeBreaker(AnyOpen, breakers...).State(); got != StateOpen {
+		t.Errorf("any open composite with open child should be open, got `%s`", got)
+	}
+}
+
+func TestCompositeRecoveringState(t *testing.T) {
+	breakers := regionBreakers(map[string]bool{"eu": true, "us": true}, "eu", "us")
+	breakers[0].mu.Lock()
+	breakers[0].toRecovering()
+	breakers[0].mu.Unlock()
+
+	if got := NewCompositeBreaker(AllOpen, breakers...).State(); got != StateRecovering {
+		t.Errorf("all open composite with recovering child should be recovering, got `%s`", got)
+	}
+
+	breakers[1].mu.Lock()
+	breakers[1].toRecovering()
+	breakers[1].mu.Unlock()
+
+	for _, mode := range []AggregationMode{AllOpen, AnyOpen} {
+		if got := NewCompositeBreaker(mode, breakers...).State(); got != StateRecovering {
+			t.Errorf("composite with all children recovering should be recovering, got `%s`", got)
+		}
+	}
+}
+
+func TestCompositeAllOpenRouting(t *testing.T) {
+	breakers := regionBreakers(map[string]bool{"eu": true}, "eu", "us", "ap")
+	composite := NewCompositeBreaker(AllOpen, breakers...)
+
+	res, err := composite.Call(regionService("served"))
+	if err != nil || res != "served" {
+		t.Errorf("call should be routed to a healthy child, got `%v`, `%v`", res, err)
+	}
+
+	// First available child serves the call
+	served := map[string]int{}
+	for _, b := range breakers {
+		served[b.name] = len(b.History())
+	}
+	want := map[string]int{"eu": 1, "us": 1, "ap": 0}
+	for name, n := range want {
+		if served[name] != n {
+			t.Errorf("`%s` should execute `%d` calls, got `%d`", name, n, served[name])
+		}
+	}
+}
+
+func TestCompositeAllOpenRejects(t *testing.T) {
+	composite := NewCompositeBreaker(AllOpen, regionBreakers(map[string]bool{"eu": true, "us": true}, "eu", "us")...)
+
+	_, err := composite.Call(regionService("any"))
+	if !errors.Is(err, ErrOpenState) {
+		t.Errorf("call should be rejected when all children are open, got `%v`", err)
+	}
+}
+
+func TestCompositeAnyOpenRejects(t *testing.T) {
+	composite := NewCompositeBreaker(AnyOpen, regionBreakers(map[string]bool{"us": true}, "eu", "us")...)
+
+	_, err := composite.Call(regionService("eu"))
+	var be *BreakerError
+	if !errors.As(err, &be) || be.Name != "us" || !errors.Is(err, ErrOpenState) {
+		t.Errorf("call should be rejected by the open child, got `%v`", err)
+	}
+}
+
+func TestCompositeAnyOpenRouting(t *testing.T) {
+	breakers := regionBreakers(map[string]bool{}, "eu", "us")
+	composite := NewCompositeBreaker(AnyOpen, breakers...)
+
+	res, err := composite.Call(regionService("served"))
+	if err != nil || res != "served" {
+		t.Errorf("call should succeed, got `%v`, `%v`", res, err)
+	}
+
+	if len(breakers[0].History()) != 1 || len(breakers[1].History()) != 0 {
+		t.Errorf("call should be routed to the first child")
+	}
+}
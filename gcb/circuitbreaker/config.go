@@ -0,0 +1,441 @@
+package circuitbreaker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+// ErrInvalidConfig is returned for settings the circuit breaker can't run with
+var ErrInvalidConfig = errors.New("invalid config")
+
+// Config describes the settings the circuit breaker runs with. In JSON and
+// YAML durations are written as strings, e.g. `"2s"` or `"150ms"`.
+type Config struct {
+	// Name used in logs and errors
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	// Failure score before transitioning to `open` state
+	FailureThreshold int `json:"failure_threshold" yaml:"failure_threshold"`
+	// Count of successful requests for transitioning to `closed` state
+	HalfOpenThreshold int `json:"half_open_threshold" yaml:"half_open_threshold"`
+	// Time interval before transitioning from `open` to `half-open` state
+	RecoveryTime time.Duration `json:"recovery_time" yaml:"recovery_time"`
+	// Timeout of requests, the fallback until adaptive timeout takes effect
+	Timeout time.Duration `json:"timeout" yaml:"timeout"`
+	// Timeout applied to the next request, reflects adaptive adjustments.
+	// Reported by `(*CircuitBreaker).Config` only, ignored otherwise.
+	EffectiveTimeout time.Duration `json:"-" yaml:"-"`
+
+	// Fraction the recovery time is randomized by, see `WithRecoveryJitter`
+	RecoveryJitter float64 `json:"recovery_jitter,omitempty" yaml:"recovery_jitter,omitempty"`
+	// Whether a timer drives recovery, see `WithAutoHalfOpen`
+	AutoHalfOpen bool `json:"auto_half_open,omitempty" yaml:"auto_half_open,omitempty"`
+	// Consecutive timeouts which open the breaker, see `WithTimeoutThreshold`
+	TimeoutThreshold int `json:"timeout_threshold,omitempty" yaml:"timeout_threshold,omitempty"`
+	// Failures allowed within the window, see `WithErrorBudget`
+	ErrorBudgetMaxFailures int `json:"error_budget_max_failures,omitempty" yaml:"error_budget_max_failures,omitempty"`
+	// Rolling window of the error budget, see `WithErrorBudget`
+	ErrorBudgetWindow time.Duration `json:"error_budget_window,omitempty" yaml:"error_budget_window,omitempty"`
+	// Age after which failures stop counting, see `WithFailureTTL`
+	FailureTTL time.Duration `json:"failure_ttl,omitempty" yaml:"failure_ttl,omitempty"`
+	// Quiet period after which the breaker resets, see `WithResetAfterIdle`
+	ResetAfterIdle time.Duration `json:"reset_after_idle,omitempty" yaml:"reset_after_idle,omitempty"`
+	// Calls per second allowed, see `WithRateLimit`
+	RateLimit float64 `json:"rate_limit,omitempty" yaml:"rate_limit,omitempty"`
+	// Burst of calls allowed, see `WithRateLimit`
+	RateLimitBurst int `json:"rate_limit_burst,omitempty" yaml:"rate_limit_burst,omitempty"`
+	// Limit of calls in flight, see `WithMaxConcurrent`
+	MaxConcurrent int `json:"max_concurrent,omitempty" yaml:"max_concurrent,omitempty"`
+	// Number of recorded call outcomes, see `WithHistory`
+	HistorySize int `json:"history_size,omitempty" yaml:"history_size,omitempty"`
+	// Retries of a failing operation, see `WithRetry`
+	MaxRetries int `json:"max_retries,omitempty" yaml:"max_retries,omitempty"`
+	// Delay before the first retry, see `WithRetry`
+	RetryBackoff time.Duration `json:"retry_backoff,omitempty" yaml:"retry_backoff,omitempty"`
+
+	// Whether results returned along with errors are discarded, see `WithResultOnError`
+	DropResultOnError bool `json:"drop_result_on_error,omitempty" yaml:"drop_result_on_error,omitempty"`
+	// Smoothing factor of the average latency, see `WithLatencyEWMA`
+	LatencyEWMA float64 `json:"latency_ewma,omitempty" yaml:"latency_ewma,omitempty"`
+	// Bounds of successful call latency buckets, see `WithLatencyBuckets`
+	LatencyBuckets []time.Duration `json:"latency_buckets,omitempty" yaml:"latency_buckets,omitempty"`
+
+	// Callers allowed to wait for the probe slot, see `WithHalfOpenQueue`
+	HalfOpenMaxWaiters int `json:"half_open_max_waiters,omitempty" yaml:"half_open_max_waiters,omitempty"`
+	// Time a caller may wait for the probe slot, see `WithHalfOpenQueue`
+	HalfOpenMaxWait time.Duration `json:"half_open_max_wait,omitempty" yaml:"half_open_max_wait,omitempty"`
+	// Minimal number of probes, see `WithHalfOpenSuccessRate`
+	HalfOpenMinCalls int `json:"half_open_min_calls,omitempty" yaml:"half_open_min_calls,omitempty"`
+	// Required success rate of probes, see `WithHalfOpenSuccessRate`
+	HalfOpenSuccessRate float64 `json:"half_open_success_rate,omitempty" yaml:"half_open_success_rate,omitempty"`
+	// Whether probe successes are cumulative, see `WithHalfOpenConsecutive`
+	HalfOpenCumulative bool `json:"half_open_cumulative,omitempty" yaml:"half_open_cumulative,omitempty"`
+	// Successful probes added per failed recovery, see `WithEscalatingHalfOpenThreshold`
+	HalfOpenThresholdStep int `json:"half_open_threshold_step,omitempty" yaml:"half_open_threshold_step,omitempty"`
+	// Upper bound of the escalated threshold, see `WithEscalatingHalfOpenThreshold`
+	HalfOpenThresholdMax int `json:"half_open_threshold_max,omitempty" yaml:"half_open_threshold_max,omitempty"`
+	// Share of calls admitted while recovering, see `WithRecoveringState`
+	RecoveringFraction float64 `json:"recovering_fraction,omitempty" yaml:"recovering_fraction,omitempty"`
+	// Successes closing the breaker, see `WithRecoveringState`
+	RecoveringSuccesses int `json:"recovering_successes,omitempty" yaml:"recovering_successes,omitempty"`
+	// Probability of admitting a request, see `WithHalfOpenTrafficPercent`
+	HalfOpenTrafficPercent float64 `json:"half_open_traffic_percent,omitempty" yaml:"half_open_traffic_percent,omitempty"`
+	// Latency limit of probes, see `WithHalfOpenMaxLatency`
+	HalfOpenMaxLatency time.Duration `json:"half_open_max_latency,omitempty" yaml:"half_open_max_latency,omitempty"`
+	// Whether slow probes re-open the breaker, see `WithSlowProbeReopen`
+	SlowProbeReopen bool `json:"slow_probe_reopen,omitempty" yaml:"slow_probe_reopen,omitempty"`
+	// Whether failed probes count after closing, see `WithProbeFailuresInWindow`
+	ProbeFailuresInWindow bool `json:"probe_failures_in_window,omitempty" yaml:"probe_failures_in_window,omitempty"`
+
+	// Latency based timeout, see `WithAdaptiveTimeout`
+	AdaptiveTimeout *AdaptiveTimeoutConfig `json:"adaptive_timeout,omitempty" yaml:"adaptive_timeout,omitempty"`
+}
+
+// AdaptiveTimeoutConfig describes the settings of `WithAdaptiveTimeout`.
+type AdaptiveTimeoutConfig struct {
+	Percentile float64       `json:"percentile" yaml:"percentile"`
+	Multiplier float64       `json:"multiplier" yaml:"multiplier"`
+	Min        time.Duration `json:"min" yaml:"min"`
+	Max        time.Duration `json:"max" yaml:"max"`
+}
+
+// NewFromConfig validates `cfg` and creates a circuit breaker from it.
+// Settings which can't be expressed in config, e.g. callbacks, are passed as
+// `opts` and applied after the config.
+func NewFromConfig(cfg Config, opts ...Option) (*CircuitBreaker, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return NewCircuitBreaker(
+		cfg.FailureThreshold, cfg.HalfOpenThreshold,
+		cfg.RecoveryTime, cfg.Timeout,
+		append(cfg.options(), opts...)...,
+	), nil
+}
+
+// Validate reports the first setting the circuit breaker can't run with.
+func (c Config) Validate() error {
+	switch {
+	case c.FailureThreshold < 1:
+		return fmt.Errorf("%w: failure threshold must be at least 1, got %d", ErrInvalidConfig, c.FailureThreshold)
+	case c.HalfOpenThreshold < 1:
+		return fmt.Errorf("%w: half-open threshold must be at least 1, got %d", ErrInvalidConfig, c.HalfOpenThreshold)
+	case c.RecoveryTime <= 0:
+		return fmt.Errorf("%w: recovery time must be positive, got %s", ErrInvalidConfig, c.RecoveryTime)
+	case c.Timeout <= 0:
+		return fmt.Errorf("%w: timeout must be positive, got %s", ErrInvalidConfig, c.Timeout)
+	case c.RecoveryJitter < 0 || c.RecoveryJitter > 1:
+		return fmt.Errorf("%w: recovery jitter must be within [0, 1], got %v", ErrInvalidConfig, c.RecoveryJitter)
+	case c.TimeoutThreshold < 0:
+		return fmt.Errorf("%w: timeout threshold can't be negative, got %d", ErrInvalidConfig, c.TimeoutThreshold)
+	case c.ErrorBudgetMaxFailures < 0:
+		return fmt.Errorf("%w: error budget max failures can't be negative, got %d", ErrInvalidConfig, c.ErrorBudgetMaxFailures)
+	case c.ErrorBudgetMaxFailures > 0 && c.ErrorBudgetWindow <= 0:
+		return fmt.Errorf("%w: error budget window must be positive, got %s", ErrInvalidConfig, c.ErrorBudgetWindow)
+	case c.FailureTTL < 0:
+		return fmt.Errorf("%w: failure ttl can't be negative, got %s", ErrInvalidConfig, c.FailureTTL)
+	case c.ResetAfterIdle < 0:
+		return fmt.Errorf("%w: reset after idle can't be negative, got %s", ErrInvalidConfig, c.ResetAfterIdle)
+	case c.HalfOpenThresholdStep < 0:
+		return fmt.Errorf("%w: half-open threshold step can't be negative, got %d", ErrInvalidConfig, c.HalfOpenThresholdStep)
+	case c.HalfOpenThresholdStep > 0 && c.HalfOpenThresholdMax < c.HalfOpenThreshold:
+		return fmt.Errorf("%w: half-open threshold max must be at least %d, got %d",
+			ErrInvalidConfig, c.HalfOpenThreshold, c.HalfOpenThresholdMax)
+	case c.RecoveringFraction < 0 || c.RecoveringFraction > 1:
+		return fmt.Errorf("%w: recovering fraction must be within [0, 1], got %v", ErrInvalidConfig, c.RecoveringFraction)
+	case c.RecoveringFraction > 0 && c.RecoveringSuccesses < 1:
+		return fmt.Errorf("%w: recovering successes must be at least 1, got %d", ErrInvalidConfig, c.RecoveringSuccesses)
+	case c.RateLimit < 0:
+		return fmt.Errorf("%w: rate limit can't be negative, got %v", ErrInvalidConfig, c.RateLimit)
+	case c.RateLimit > 0 && c.RateLimitBurst < 1:
+		return fmt.Errorf("%w: rate limit burst must be at least 1, got %d", ErrInvalidConfig, c.RateLimitBurst)
+	case c.MaxConcurrent < 0:
+		return fmt.Errorf("%w: max concurrent can't be negative, got %d", ErrInvalidConfig, c.MaxConcurrent)
+	case c.HistorySize < 0:
+		return fmt.Errorf("%w: history size can't be negative, got %d", ErrInvalidConfig, c.HistorySize)
+	case c.MaxRetries < 0:
+		return fmt.Errorf("%w: max retries can't be negative, got %d", ErrInvalidConfig, c.MaxRetries)
+	case c.RetryBackoff < 0:
+		return fmt.Errorf("%w: retry backoff can't be negative, got %s", ErrInvalidConfig, c.RetryBackoff)
+	case c.LatencyEWMA < 0 || c.LatencyEWMA > 1:
+		return fmt.Errorf("%w: latency ewma must be within [0, 1], got %v", ErrInvalidConfig, c.LatencyEWMA)
+	case slices.ContainsFunc(c.LatencyBuckets, func(d time.Duration) bool { return d <= 0 }):
+		return fmt.Errorf("%w: latency bucket bounds must be positive, got %v", ErrInvalidConfig, c.LatencyBuckets)
+	case c.HalfOpenMaxWaiters < 0:
+		return fmt.Errorf("%w: half-open max waiters can't be negative, got %d", ErrInvalidConfig, c.HalfOpenMaxWaiters)
+	case c.HalfOpenMaxWait < 0:
+		return fmt.Errorf("%w: half-open max wait can't be negative, got %s", ErrInvalidConfig, c.HalfOpenMaxWait)
+	case c.HalfOpenMinCalls < 0:
+		return fmt.Errorf("%w: half-open min calls can't be negative, got %d", ErrInvalidConfig, c.HalfOpenMinCalls)
+	case c.HalfOpenSuccessRate < 0 || c.HalfOpenSuccessRate > 1:
+		return fmt.Errorf("%w: half-open success rate must be within [0, 1], got %v", ErrInvalidConfig, c.HalfOpenSuccessRate)
+	case c.HalfOpenTrafficPercent < 0 || c.HalfOpenTrafficPercent > 1:
+		return fmt.Errorf("%w: half-open traffic percent must be within [0, 1], got %v", ErrInvalidConfig, c.HalfOpenTrafficPercent)
+	case c.HalfOpenMaxLatency < 0:
+		return fmt.Errorf("%w: half-open max latency can't be negative, got %s", ErrInvalidConfig, c.HalfOpenMaxLatency)
+	}
+
+	if a := c.AdaptiveTimeout; a != nil {
+		switch {
+		case a.Percentile <= 0 || a.Percentile > 1:
+			return fmt.Errorf("%w: adaptive percentile must be within (0, 1], got %v", ErrInvalidConfig, a.Percentile)
+		case a.Multiplier <= 0:
+			return fmt.Errorf("%w: adaptive multiplier must be positive, got %v", ErrInvalidConfig, a.Multiplier)
+		case a.Min <= 0 || a.Min > a.Max:
+			return fmt.Errorf("%w: adaptive bounds must satisfy 0 < min <= max, got [%s, %s]", ErrInvalidConfig, a.Min, a.Max)
+		}
+	}
+
+	return nil
+}
+
+// options translates optional settings into options.
+func (c Config) options() []Option {
+	opts := []Option{
+		WithRecoveryJitter(c.RecoveryJitter),
+		WithAutoHalfOpen(c.AutoHalfOpen),
+		WithTimeoutThreshold(c.TimeoutThreshold),
+		WithErrorBudget(c.ErrorBudgetMaxFailures, c.ErrorBudgetWindow),
+		WithFailureTTL(c.FailureTTL),
+		WithResetAfterIdle(c.ResetAfterIdle),
+		WithRateLimit(c.RateLimit, c.RateLimitBurst),
+		WithMaxConcurrent(c.MaxConcurrent),
+		WithHistory(c.HistorySize),
+		WithRetry(c.MaxRetries, c.RetryBackoff),
+		WithResultOnError(!c.DropResultOnError),
+		WithLatencyEWMA(c.LatencyEWMA),
+		WithLatencyBuckets(c.LatencyBuckets),
+		WithHalfOpenQueue(c.HalfOpenMaxWaiters, c.HalfOpenMaxWait),
+		WithHalfOpenSuccessRate(c.HalfOpenMinCalls, c.HalfOpenSuccessRate),
+		WithHalfOpenConsecutive(!c.HalfOpenCumulative),
+		WithEscalatingHalfOpenThreshold(c.HalfOpenThresholdStep, c.HalfOpenThresholdMax),
+		WithRecoveringState(c.RecoveringFraction, c.RecoveringSuccesses),
+		WithHalfOpenTrafficPercent(c.HalfOpenTrafficPercent),
+		WithHalfOpenMaxLatency(c.HalfOpenMaxLatency),
+		WithSlowProbeReopen(c.SlowProbeReopen),
+		WithProbeFailuresInWindow(c.ProbeFailuresInWindow),
+	}
+
+	if c.Name != "" {
+		opts = append(opts, WithName(c.Name))
+	}
+
+	if a := c.AdaptiveTimeout; a != nil {
+		opts = append(opts, WithAdaptiveTimeout(a.Percentile, a.Multiplier, a.Min, a.Max))
+	}
+
+	return opts
+}
+
+// Config returns the settings the circuit breaker currently runs with.
+func (cb *CircuitBreaker) Config() Config {
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	cfg := Config{
+		Name:                   cb.name,
+		FailureThreshold:       cb.failureThreshold,
+		HalfOpenThreshold:      cb.halfOpenThreshold,
+		RecoveryTime:           cb.recoveryTime,
+		Timeout:                cb.timeout,
+		EffectiveTimeout:       cb.effectiveTimeout(),
+		RecoveryJitter:         cb.recoveryJitter,
+		AutoHalfOpen:           cb.autoHalfOpen,
+		TimeoutThreshold:       cb.timeoutThreshold,
+		ErrorBudgetMaxFailures: cb.budgetMaxFailures,
+		ErrorBudgetWindow:      cb.budgetWindow,
+		FailureTTL:             cb.failureTTL,
+		ResetAfterIdle:         cb.idleReset,
+		RateLimit:              cb.rateLimit,
+		RateLimitBurst:         cb.rateBurst,
+		MaxConcurrent:          cb.maxConcurrent,
+		MaxRetries:             cb.maxRetries,
+		RetryBackoff:           cb.retryBackoff,
+		DropResultOnError:      cb.dropResultOnError,
+		LatencyEWMA:            cb.stats.alpha,
+		LatencyBuckets:         slices.Clone(cb.stats.bucketBounds),
+		HalfOpenMaxWaiters:     cb.halfOpenMaxWaiters,
+		HalfOpenMaxWait:        cb.halfOpenMaxWait,
+		HalfOpenMinCalls:       cb.halfOpenMinCalls,
+		HalfOpenSuccessRate:    cb.halfOpenSuccessRate,
+		HalfOpenCumulative:     cb.halfOpenCumulative,
+		HalfOpenThresholdStep:  cb.halfOpenStep,
+		HalfOpenThresholdMax:   cb.halfOpenMax,
+		RecoveringFraction:     cb.recoveringFraction,
+		RecoveringSuccesses:    cb.recoveringSuccesses,
+		HalfOpenTrafficPercent: cb.halfOpenTrafficPercent,
+		HalfOpenMaxLatency:     cb.halfOpenMaxLatency,
+		SlowProbeReopen:        cb.slowProbeReopens,
+		ProbeFailuresInWindow:  cb.probeFailuresInWindow,
+	}
+
+	if cb.history != nil {
+		cfg.HistorySize = len(cb.history.records)
+	}
+
+	if a := cb.adaptive; a != nil {
+		cfg.AdaptiveTimeout = &AdaptiveTimeoutConfig{
+			Percentile: a.percentile,
+			Multiplier: a.multiplier,
+			Min:        a.min,
+			Max:        a.max,
+		}
+	}
+
+	return cfg
+}
+
+// duration is encoded in JSON as a string, e.g. `"2s"`.
+type duration time.Duration
+
+func (d duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"2s\", got %s", data)
+	}
+
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = duration(v)
+	return nil
+}
+
+// durations converts `ds` for JSON encoding, keeping `nil` as is.
+func durations(ds []time.Duration) []duration {
+	if ds == nil {
+		return nil
+	}
+
+	out := make([]duration, len(ds))
+	for i, d := range ds {
+		out[i] = duration(d)
+	}
+	return out
+}
+
+// configJSON shadows duration fields of `Config` for JSON encoding.
+type configJSON struct {
+	*configPlain
+	RecoveryTime       duration   `json:"recovery_time"`
+	Timeout            duration   `json:"timeout"`
+	ErrorBudgetWindow  duration   `json:"error_budget_window,omitempty"`
+	FailureTTL         duration   `json:"failure_ttl,omitempty"`
+	ResetAfterIdle     duration   `json:"reset_after_idle,omitempty"`
+	RetryBackoff       duration   `json:"retry_backoff,omitempty"`
+	HalfOpenMaxWait    duration   `json:"half_open_max_wait,omitempty"`
+	HalfOpenMaxLatency duration   `json:"half_open_max_latency,omitempty"`
+	LatencyBuckets     []duration `json:"latency_buckets,omitempty"`
+}
+
+// configPlain is `Config` without JSON methods.
+type configPlain Config
+
+func (c Config) MarshalJSON() ([]byte, error) {
+	return json.Marshal(configJSON{
+		configPlain:        (*configPlain)(&c),
+		RecoveryTime:       duration(c.RecoveryTime),
+		Timeout:            duration(c.Timeout),
+		ErrorBudgetWindow:  duration(c.ErrorBudgetWindow),
+		FailureTTL:         duration(c.FailureTTL),
+		ResetAfterIdle:     duration(c.ResetAfterIdle),
+		RetryBackoff:       duration(c.RetryBackoff),
+		HalfOpenMaxWait:    duration(c.HalfOpenMaxWait),
+		HalfOpenMaxLatency: duration(c.HalfOpenMaxLatency),
+		LatencyBuckets:     durations(c.LatencyBuckets),
+	})
+}
+
+func (c *Config) UnmarshalJSON(data []byte) error {
+	aux := configJSON{configPlain: (*configPlain)(c)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	c.RecoveryTime = time.Duration(aux.RecoveryTime)
+	c.Timeout = time.Duration(aux.Timeout)
+	c.ErrorBudgetWindow = time.Duration(aux.ErrorBudgetWindow)
+	c.FailureTTL = time.Duration(aux.FailureTTL)
+	c.ResetAfterIdle = time.Duration(aux.ResetAfterIdle)
+	c.RetryBackoff = time.Duration(aux.RetryBackoff)
+	c.HalfOpenMaxWait = time.Duration(aux.HalfOpenMaxWait)
+	c.HalfOpenMaxLatency = time.Duration(aux.HalfOpenMaxLatency)
+	c.LatencyBuckets = nil
+	for _, d := range aux.LatencyBuckets {
+		c.LatencyBuckets = append(c.LatencyBuckets, time.Duration(d))
+	}
+
+	return nil
+}
+
+// adaptiveTimeoutJSON shadows duration fields of `AdaptiveTimeoutConfig`.
+type adaptiveTimeoutJSON struct {
+	*adaptiveTimeoutPlain
+	Min duration `json:"min"`
+	Max duration `json:"max"`
+}
+
+// adaptiveTimeoutPlain is `AdaptiveTimeoutConfig` without JSON methods.
+type adaptiveTimeoutPlain AdaptiveTimeoutConfig
+
+func (a AdaptiveTimeoutConfig) MarshalJSON() ([]byte, error) {
+	return json.Marshal(adaptiveTimeoutJSON{
+		adaptiveTimeoutPlain: (*adaptiveTimeoutPlain)(&a),
+		Min:                  duration(a.Min),
+		Max:                  duration(a.Max),
+	})
+}
+
+func (a *AdaptiveTimeoutConfig) UnmarshalJSON(data []byte) error {
+	aux := adaptiveTimeoutJSON{adaptiveTimeoutPlain: (*adaptiveTimeoutPlain)(a)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	a.Min = time.Duration(aux.Min)
+	a.Max = time.Duration(aux.Max)
+
+	return nil
+}
+
+// UpdateConfig replaces the thresholds, recovery time and timeout of the
+// circuit breaker with ones from `cfg`, e.g. to tighten them during an
+// incident. Other settings are left as they are. State and counters are
+// preserved and the new settings govern the next call, e.g. a failure score
+// already above a lowered failure threshold opens the breaker on the next
+// failure. Recovery time applies to the current `open` cycle as well. With
+// adaptive timeout `Timeout` is the fallback used until enough calls succeed.
+func (cb *CircuitBreaker) UpdateConfig(cfg Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	cb.failureThreshold = cfg.FailureThreshold
+	cb.halfOpenThreshold = cfg.HalfOpenThreshold
+	cb.recoveryTime = cfg.RecoveryTime
+	cb.timeout = cfg.Timeout
+	cb.clamp()
+	cb.halfOpenEscalation = min(cb.halfOpenEscalation, max(0, cb.halfOpenMax-cb.halfOpenThreshold))
+
+	slog.Info("config updated", "name", cb.name, "state", cb.loadState())
+
+	if cb.loadState() == StateOpen {
+		cb.cycleRecoveryTime = cb.jitteredRecoveryTime()
+		cb.scheduleRecovery()
+	}
+
+	return nil
+}
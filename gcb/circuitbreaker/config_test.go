@@ -0,0 +1,368 @@
+package circuitbreaker
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfig(t *testing.T) {
+	cb := NewCircuitBreaker(3, 2, 5*time.Second, time.Second)
+
+	want := Config{
+		Name:              defaultName,
+		FailureThreshold:  3,
+		HalfOpenThreshold: 2,
+		RecoveryTime:      5 * time.Second,
+		Timeout:           time.Second,
+		EffectiveTimeout:  time.Second,
+	}
+	if got := cb.Config(); !reflect.DeepEqual(got, want) {
+		t.Errorf("config should be `%+v`, got `%+v`", want, got)
+	}
+}
+
+func TestConfigAdaptiveTimeout(t *testing.T) {
+	cb := NewCircuitBreaker(3, 2, 5*time.Second, time.Second,
+		WithAdaptiveTimeout(0.5, 2, time.Millisecond, time.Second))
+
+	for i := 0; i < adaptiveMinSamples; i++ {
+		cb.Call(func() (any, error) {
+			time.Sleep(10 * time.Millisecond)
+			return "OK", nil
+		})
+	}
+
+	cfg := cb.Config()
+	got := cfg.EffectiveTimeout
+	if got == time.Second || got < 20*time.Millisecond || got > 100*time.Millisecond {
+		t.Errorf("config should reflect adjusted timeout, got `%s`", got)
+	}
+
+	if got != cb.effectiveTimeout() {
+		t.Errorf("config timeout should match effective timeout `%s`, got `%s`", cb.effectiveTimeout(), got)
+	}
+	if cfg.Timeout != time.Second {
+		t.Errorf("config should keep the configured timeout, got `%s`", cfg.Timeout)
+	}
+}
+
+func TestConfigAdaptiveTimeoutRoundTrip(t *testing.T) {
+	cb := NewCircuitBreaker(3, 2, 5*time.Second, 2*time.Second,
+		WithAdaptiveTimeout(0.5, 1, time.Millisecond, time.Second))
+	for i := 0; i < adaptiveMinSamples; i++ {
+		cb.Call(makeService(0, 1, 0))
+	}
+
+	cfg := cb.Config()
+	cfg.FailureThreshold = 2
+	if err := cb.UpdateConfig(cfg); err != nil {
+		t.Fatalf("config read back should be valid, got `%v`", err)
+	}
+	if got := cb.Config().Timeout; got != 2*time.Second {
+		t.Errorf("update should keep the configured timeout, got `%s`", got)
+	}
+
+	clone, err := NewFromConfig(cb.Config())
+	if err != nil {
+		t.Fatalf("config read back should create a breaker, got `%v`", err)
+	}
+	if got := clone.Config().Timeout; got != 2*time.Second {
+		t.Errorf("breaker created from config should keep the configured timeout, got `%s`", got)
+	}
+}
+
+const representativeConfig = `{
+	"name": "payments",
+	"failure_threshold": 2,
+	"half_open_threshold": 1,
+	"recovery_time": "50ms",
+	"timeout": "2s",
+	"error_budget_max_failures": 50,
+	"error_budget_window": "1m",
+	"failure_ttl": "1m",
+	"max_concurrent": 8,
+	"history_size": 16,
+	"max_retries": 1,
+	"retry_backoff": "10ms",
+	"half_open_max_waiters": 4,
+	"half_open_max_wait": "100ms",
+	"half_open_max_latency": "500ms",
+	"latency_buckets": ["50ms", "100ms", "500ms"],
+	"adaptive_timeout": {"percentile": 0.99, "multiplier": 2, "min": "100ms", "max": "2s"}
+}`
+
+func TestConfigUnmarshalJSON(t *testing.T) {
+	var cfg Config
+	if err := json.Unmarshal([]byte(representativeConfig), &cfg); err != nil {
+		t.Fatalf("config should unmarshal, got `%v`", err)
+	}
+
+	want := Config{
+		Name:                   "payments",
+		FailureThreshold:       2,
+		HalfOpenThreshold:      1,
+		RecoveryTime:           50 * time.Millisecond,
+		Timeout:                2 * time.Second,
+		FailureTTL:             time.Minute,
+		ErrorBudgetMaxFailures: 50,
+		ErrorBudgetWindow:      time.Minute,
+		MaxConcurrent:          8,
+		HistorySize:            16,
+		MaxRetries:             1,
+		RetryBackoff:           10 * time.Millisecond,
+		HalfOpenMaxWaiters:     4,
+		HalfOpenMaxWait:        100 * time.Millisecond,
+		HalfOpenMaxLatency:     500 * time.Millisecond,
+		LatencyBuckets:         []time.Duration{50 * time.Millisecond, 100 * time.Millisecond, 500 * time.Millisecond},
+	}
+	adaptive := AdaptiveTimeoutConfig{Percentile: 0.99, Multiplier: 2, Min: 100 * time.Millisecond, Max: 2 * time.Second}
+
+	if cfg.AdaptiveTimeout == nil || *cfg.AdaptiveTimeout != adaptive {
+		t.Errorf("adaptive timeout should be `%+v`, got `%+v`", adaptive, cfg.AdaptiveTimeout)
+	}
+
+	cfg.AdaptiveTimeout = nil
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("config should be `%+v`, got `%+v`", want, cfg)
+	}
+}
+
+func TestConfigJSONRoundTrip(t *testing.T) {
+	var cfg Config
+	if err := json.Unmarshal([]byte(representativeConfig), &cfg); err != nil {
+		t.Fatalf("config should unmarshal, got `%v`", err)
+	}
+
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatalf("config should marshal, got `%v`", err)
+	}
+	if !strings.Contains(string(data), `"recovery_time":"50ms"`) {
+		t.Errorf("durations should marshal as strings, got `%s`", data)
+	}
+
+	var got Config
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("marshaled config should unmarshal, got `%v`", err)
+	}
+	if *got.AdaptiveTimeout != *cfg.AdaptiveTimeout {
+		t.Errorf("adaptive timeout should round trip as `%+v`, got `%+v`", cfg.AdaptiveTimeout, got.AdaptiveTimeout)
+	}
+
+	got.AdaptiveTimeout, cfg.AdaptiveTimeout = nil, nil
+	if !reflect.DeepEqual(got, cfg) {
+		t.Errorf("config should round trip as `%+v`, got `%+v`", cfg, got)
+	}
+}
+
+func TestConfigUnmarshalInvalidDuration(t *testing.T) {
+	for _, data := range []string{
+		`{"recovery_time": "soon"}`,
+		`{"timeout": 1000}`,
+		`{"adaptive_timeout": {"min": "1 second"}}`,
+		`{"latency_buckets": [50]}`,
+	} {
+		var cfg Config
+		if err := json.Unmarshal([]byte(data), &cfg); err == nil {
+			t.Errorf("config `%s` should fail to unmarshal", data)
+		}
+	}
+}
+
+func TestNewFromConfig(t *testing.T) {
+	var cfg Config
+	if err := json.Unmarshal([]byte(representativeConfig), &cfg); err != nil {
+		t.Fatalf("config should unmarshal, got `%v`", err)
+	}
+
+	cb, err := NewFromConfig(cfg)
+	if err != nil {
+		t.Fatalf("valid config should create a breaker, got `%v`", err)
+	}
+
+	got := cb.Config()
+	if *got.AdaptiveTimeout != *cfg.AdaptiveTimeout {
+		t.Errorf("adaptive timeout should be `%+v`, got `%+v`", cfg.AdaptiveTimeout, got.AdaptiveTimeout)
+	}
+
+	got.AdaptiveTimeout, cfg.AdaptiveTimeout = nil, nil
+	// No latencies observed yet
+	cfg.EffectiveTimeout = cfg.Timeout
+	if !reflect.DeepEqual(got, cfg) {
+		t.Errorf("breaker should run with `%+v`, got `%+v`", cfg, got)
+	}
+}
+
+func TestNewFromConfigBehavior(t *testing.T) {
+	cb, err := NewFromConfig(Config{
+		Name:              "payments",
+		FailureThreshold:  2,
+		HalfOpenThreshold: 1,
+		RecoveryTime:      50 * time.Millisecond,
+		Timeout:           time.Second,
+		HistorySize:       4,
+	})
+	if err != nil {
+		t.Fatalf("valid config should create a breaker, got `%v`", err)
+	}
+
+	service := makeService(1, 2, 100)
+	cb.Call(service)
+	cb.Call(service)
+
+	_, err = cb.Call(service)
+	if !errors.Is(err, ErrOpenState) {
+		t.Fatalf("breaker should open after 2 failures, got `%v`", err)
+	}
+	if !strings.Contains(err.Error(), `"payments"`) {
+		t.Errorf("error should carry the configured name, got `%v`", err)
+	}
+
+	if n := len(cb.History()); n != 2 {
+		t.Errorf("history should record 2 executed calls, got %d", n)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	cb.Call(makeService(1, 2, 0))
+	cb.Call(makeService(1, 2, 0))
+	if s := cb.State(); s != StateClosed {
+		t.Errorf("breaker should close after recovery time, got `%s`", s)
+	}
+}
+
+func TestNewFromConfigOptions(t *testing.T) {
+	cb, err := NewFromConfig(
+		Config{FailureThreshold: 1, HalfOpenThreshold: 1, RecoveryTime: time.Second, Timeout: time.Second},
+		WithName("override"),
+	)
+	if err != nil {
+		t.Fatalf("valid config should create a breaker, got `%v`", err)
+	}
+
+	if name := cb.Config().Name; name != "override" {
+		t.Errorf("options should apply after config, got name `%s`", name)
+	}
+}
+
+func TestNewFromConfigValidation(t *testing.T) {
+	valid := Config{FailureThreshold: 1, HalfOpenThreshold: 1, RecoveryTime: time.Second, Timeout: time.Second}
+
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		field  string
+	}{
+		{"failure threshold", func(c *Config) { c.FailureThreshold = 0 }, "failure threshold"},
+		{"half-open threshold", func(c *Config) { c.HalfOpenThreshold = -1 }, "half-open threshold"},
+		{"recovery time", func(c *Config) { c.RecoveryTime = 0 }, "recovery time"},
+		{"timeout", func(c *Config) { c.Timeout = -time.Second }, "timeout"},
+		{"max concurrent", func(c *Config) { c.MaxConcurrent = -1 }, "max concurrent"},
+		{"half-open threshold max", func(c *Config) { c.HalfOpenThresholdStep = 1 }, "half-open threshold max"},
+		{"rate limit burst", func(c *Config) { c.RateLimit = 10 }, "rate limit burst"},
+		{"success rate", func(c *Config) { c.HalfOpenSuccessRate = 1.5 }, "success rate"},
+		{"latency buckets", func(c *Config) { c.LatencyBuckets = []time.Duration{time.Second, 0} }, "latency bucket"},
+		{"adaptive percentile", func(c *Config) {
+			c.AdaptiveTimeout = &AdaptiveTimeoutConfig{Percentile: 0, Multiplier: 1, Min: 1, Max: 2}
+		}, "percentile"},
+		{"adaptive bounds", func(c *Config) {
+			c.AdaptiveTimeout = &AdaptiveTimeoutConfig{Percentile: 0.9, Multiplier: 1, Min: time.Second, Max: time.Millisecond}
+		}, "bounds"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid
+			tt.modify(&cfg)
+
+			cb, err := NewFromConfig(cfg)
+			if cb != nil {
+				t.Error("invalid config should not create a breaker")
+			}
+			if !errors.Is(err, ErrInvalidConfig) {
+				t.Fatalf("error should be `%v`, got `%v`", ErrInvalidConfig, err)
+			}
+			if !strings.Contains(err.Error(), tt.field) {
+				t.Errorf("error should mention `%s`, got `%v`", tt.field, err)
+			}
+		})
+	}
+}
+
+func TestUpdateConfig(t *testing.T) {
+	cb := NewCircuitBreaker(5, 1, time.Minute, time.Second)
+	callOutcomes(cb, false, false, false)
+
+	cfg := cb.Config()
+	cfg.FailureThreshold = 2
+	if err := cb.UpdateConfig(cfg); err != nil {
+		t.Fatalf("valid config should be applied, got `%v`", err)
+	}
+
+	if cb.State() != StateClosed || cb.Snapshot().FailureCount != 3 {
+		t.Fatalf("update should keep state and counters, got `%+v`", cb.Snapshot())
+	}
+	if got := cb.Config(); !reflect.DeepEqual(got, cfg) {
+		t.Errorf("breaker should run with `%+v`, got `%+v`", cfg, got)
+	}
+
+	// Failure count above the lowered threshold trips on the next failure
+	callOutcomes(cb, false)
+	if cb.State() != StateOpen {
+		t.Errorf("next failure should open the breaker, got `%s`", cb.State())
+	}
+}
+
+func TestUpdateConfigGovernsTrips(t *testing.T) {
+	cb := NewCircuitBreaker(5, 1, time.Minute, time.Second)
+	callOutcomes(cb, false)
+
+	cfg := cb.Config()
+	cfg.FailureThreshold = 3
+	cb.UpdateConfig(cfg)
+
+	callOutcomes(cb, false)
+	if cb.State() != StateClosed {
+		t.Fatalf("breaker should stay closed below the new threshold, got `%s`", cb.State())
+	}
+	callOutcomes(cb, false)
+	if cb.State() != StateOpen {
+		t.Errorf("carried over failure should count towards the new threshold, got `%s`", cb.State())
+	}
+}
+
+func TestUpdateConfigRecoveryTime(t *testing.T) {
+	clock := NewFakeClock()
+	cb := NewCircuitBreaker(1, 1, time.Hour, time.Second, WithClock(clock))
+	callOutcomes(cb, false)
+
+	cfg := cb.Config()
+	cfg.RecoveryTime = time.Second
+	cb.UpdateConfig(cfg)
+
+	if left := cb.RetryAfter(); left != time.Second {
+		t.Errorf("new recovery time should apply to the current open cycle, got `%s`", left)
+	}
+
+	clock.Advance(2 * time.Second)
+	if _, outcome, _ := cb.CallDetailed(makeService(0, 1, 0)); outcome != OutcomeTransitioned {
+		t.Errorf("breaker should attempt recovery after the new recovery time, got `%s`", outcome)
+	}
+}
+
+func TestUpdateConfigInvalid(t *testing.T) {
+	cb := NewCircuitBreaker(3, 1, time.Minute, time.Second)
+	want := cb.Config()
+
+	cfg := want
+	cfg.FailureThreshold = 0
+	if err := cb.UpdateConfig(cfg); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("error should be `%v`, got `%v`", ErrInvalidConfig, err)
+	}
+	if got := cb.Config(); !reflect.DeepEqual(got, want) {
+		t.Errorf("invalid config shouldn't be applied, got `%+v`", got)
+	}
+}
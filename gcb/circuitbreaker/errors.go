@@ -0,0 +1,37 @@
+package circuitbreaker
+
+import (
+	"fmt"
+	"time"
+)
+
+// BreakerError is returned for calls rejected by the circuit breaker. It
+// unwraps to the sentinel error describing the reason, e.g. `ErrOpenState`.
+type BreakerError struct {
+	// Name of the breaker which rejected the call
+	Name string
+	// State of the breaker at the time of rejection
+	State State
+	// Time left until the breaker attempts recovery, zero if unknown
+	RetryAfter time.Duration
+	// Sentinel error describing the reason
+	Err error
+}
+
+func (e *BreakerError) Error() string {
+	return fmt.Sprintf("breaker %q: %s", e.Name, e.Err)
+}
+
+func (e *BreakerError) Unwrap() error {
+	return e.Err
+}
+
+// reject builds the error for a call rejected for `reason`.
+func (cb *CircuitBreaker) reject(reason error) *BreakerError {
+	return &BreakerError{
+		Name:       cb.name,
+		State:      cb.loadState(),
+		RetryAfter: cb.retryAfter(),
+		Err:        reason,
+	}
+}
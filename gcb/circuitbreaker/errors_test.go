@@ -0,0 +1,75 @@
+package circuitbreaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBreakerErrorOpenState(t *testing.T) {
+	cb := NewCircuitBreaker(1, 1, 2*time.Second, 2*time.Second, WithName("payments"))
+	// Always failing service
+	s := makeService(20, 50, 100)
+
+	cb.Call(s)
+	_, err := cb.Call(s)
+
+	var be *BreakerError
+	if !errors.As(err, &be) {
+		t.Fatalf("rejection should be a breaker error, got `%v`", err)
+	}
+
+	if be.State != StateOpen {
+		t.Errorf("breaker error should carry open state, got `%s`", be.State)
+	}
+
+	if be.Name != "payments" {
+		t.Errorf("breaker error should carry breaker name, got `%s`", be.Name)
+	}
+
+	if be.RetryAfter <= time.Second || be.RetryAfter > 2*time.Second {
+		t.Errorf("retry after should be close to recovery time, got `%s`", be.RetryAfter)
+	}
+
+	if !errors.Is(err, ErrOpenState) {
+		t.Errorf("breaker error should unwrap to open state error, got `%v`", err)
+	}
+}
+
+func TestBreakerErrorTooManyRequests(t *testing.T) {
+	cb := NewCircuitBreaker(1, 1, 20*time.Millisecond, 2*time.Second)
+	makeHalfOpen(t, cb)
+
+	release := make(chan struct{})
+	defer close(release)
+	cb.CallAsync(func() (any, error) {
+		<-release
+		return "OK", nil
+	})
+	time.Sleep(10 * time.Millisecond)
+
+	_, err := cb.Call(makeService(10, 20, 0))
+
+	var be *BreakerError
+	if !errors.As(err, &be) {
+		t.Fatalf("rejection should be a breaker error, got `%v`", err)
+	}
+
+	if be.State != StateHalfOpen || be.RetryAfter != 0 {
+		t.Errorf("breaker error should carry half open state, got `%+v`", be)
+	}
+
+	if !errors.Is(err, ErrTooManyRequests) {
+		t.Errorf("breaker error should unwrap to too many requests error, got `%v`", err)
+	}
+}
+
+func TestBreakerErrorNotForOperationErrors(t *testing.T) {
+	cb := NewCircuitBreaker(2, 1, 2*time.Second, 2*time.Second)
+	_, err := cb.Call(makeService(20, 50, 100))
+
+	var be *BreakerError
+	if errors.As(err, &be) {
+		t.Errorf("operation error shouldn't be a breaker error, got `%v`", err)
+	}
+}
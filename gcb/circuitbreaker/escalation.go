@@ -0,0 +1,34 @@
+package circuitbreaker
+
+// WithEscalatingHalfOpenThreshold requires more successful probes to close a
+// breaker which keeps failing to recover. Each time it re-opens after a failed
+// recovery the required successes grow by `step`, up to `maxThreshold`, and
+// each time it closes they drop by `step` back towards `halfOpenThreshold`.
+// Zero `step` keeps the threshold fixed. Ignored when recovery is decided by
+// the success rate, see `WithHalfOpenSuccessRate`.
+func WithEscalatingHalfOpenThreshold(step, maxThreshold int) Option {
+	return func(cb *CircuitBreaker) {
+		cb.halfOpenStep = step
+		cb.halfOpenMax = maxThreshold
+	}
+}
+
+// effectiveHalfOpenThreshold returns the number of successful probes which
+// close the breaker in the current recovery.
+func (cb *CircuitBreaker) effectiveHalfOpenThreshold() int {
+	return cb.halfOpenThreshold + cb.halfOpenEscalation
+}
+
+// escalate raises the required successful probes after a failed recovery.
+func (cb *CircuitBreaker) escalate() {
+	if cb.halfOpenStep <= 0 {
+		return
+	}
+
+	cb.halfOpenEscalation = min(cb.halfOpenEscalation+cb.halfOpenStep, cb.halfOpenMax-cb.halfOpenThreshold)
+}
+
+// deescalate lowers the required successful probes after the breaker closed.
+func (cb *CircuitBreaker) deescalate() {
+	cb.halfOpenEscalation = max(0, cb.halfOpenEscalation-cb.halfOpenStep)
+}
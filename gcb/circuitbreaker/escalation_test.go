@@ -0,0 +1,82 @@
+package circuitbreaker
+
+import (
+	"testing"
+	"time"
+)
+
+// failRecovery lets the open breaker attempt recovery with a failing probe.
+func failRecovery(cb *CircuitBreaker, clock *FakeClock) {
+	clock.Advance(2 * time.Second)
+	// Transition to half open, failed probe re-opens
+	callOutcomes(cb, true, false)
+}
+
+// successesToClose lets the open breaker recover and counts successful
+// probes required to close it.
+func successesToClose(t *testing.T, cb *CircuitBreaker, clock *FakeClock) int {
+	t.Helper()
+
+	clock.Advance(2 * time.Second)
+	// Transition to half open
+	callOutcomes(cb, true)
+
+	n := 0
+	for cb.State() == StateHalfOpen && n < 100 {
+		callOutcomes(cb, true)
+		n++
+	}
+	if cb.State() != StateClosed {
+		t.Fatalf("successful probes should close the breaker, got `%s`", cb.State())
+	}
+
+	return n
+}
+
+func TestEscalatingHalfOpenThreshold(t *testing.T) {
+	for failed, want := range []int{1, 2, 3, 3} {
+		clock := NewFakeClock()
+		cb := NewCircuitBreaker(1, 1, time.Second, time.Second, WithClock(clock),
+			WithEscalatingHalfOpenThreshold(1, 3))
+
+		callOutcomes(cb, false)
+		for range failed {
+			failRecovery(cb, clock)
+		}
+
+		if got := successesToClose(t, cb, clock); got != want {
+			t.Errorf("after %d failed recoveries breaker should close after %d successes, got %d", failed, want, got)
+		}
+	}
+}
+
+func TestEscalatingHalfOpenThresholdDecays(t *testing.T) {
+	clock := NewFakeClock()
+	cb := NewCircuitBreaker(1, 2, time.Second, time.Second, WithClock(clock),
+		WithEscalatingHalfOpenThreshold(2, 10))
+
+	callOutcomes(cb, false)
+	failRecovery(cb, clock)
+	failRecovery(cb, clock)
+
+	// Each close steps the threshold back towards the base
+	for _, want := range []int{6, 4, 2, 2} {
+		if got := successesToClose(t, cb, clock); got != want {
+			t.Errorf("breaker should close after %d successes, got %d", want, got)
+		}
+		callOutcomes(cb, false)
+	}
+}
+
+func TestEscalatingHalfOpenThresholdClamped(t *testing.T) {
+	clock := NewFakeClock()
+	cb := NewCircuitBreaker(1, 2, time.Second, time.Second, WithClock(clock),
+		WithEscalatingHalfOpenThreshold(1, 0))
+
+	callOutcomes(cb, false)
+	failRecovery(cb, clock)
+
+	if got := successesToClose(t, cb, clock); got != 2 {
+		t.Errorf("max below the threshold should keep it fixed, got %d", got)
+	}
+}
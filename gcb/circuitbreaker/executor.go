@@ -0,0 +1,140 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrExecutorClosed is returned for operations submitted to a closed
+// `PoolExecutor`. Such calls don't count as failures.
+var ErrExecutorClosed = errors.New("executor is closed")
+
+// ErrExecutorSaturated is returned for operations which found no free worker
+// of a `PoolExecutor` within the timeout of their call.
+var ErrExecutorSaturated = errors.New("executor is saturated")
+
+// Executor runs operations of calls made through the circuit breaker.
+// `Execute` returns once `fn` completes or `ctx` is done, whichever comes
+// first, returning `ctx.Err()` in the latter case. `fn` may keep running
+// after `Execute` returned, it's given the context of the call to stop early.
+// Executors which can't start `fn` before the timeout of the call, i.e. `ctx`
+// is done with `ErrTimeout` as its `context.Cause`, return
+// `ErrExecutorSaturated` instead, the call then doesn't count as a failure.
+type Executor interface {
+	Execute(ctx context.Context, fn func() (any, error)) (any, error)
+}
+
+// WithExecutor sets how operations are run, e.g. on a `PoolExecutor`. By
+// default each operation runs on a goroutine of its own.
+func WithExecutor(e Executor) Option {
+	return func(cb *CircuitBreaker) {
+		cb.executor = e
+	}
+}
+
+// result is the outcome of an operation passed between goroutines.
+type result struct {
+	value any
+	err   error
+}
+
+// goroutineExecutor runs every operation on a new goroutine.
+type goroutineExecutor struct{}
+
+func (goroutineExecutor) Execute(ctx context.Context, fn func() (any, error)) (any, error) {
+	resChan := make(chan result, 1)
+	go func() {
+		res, err := fn()
+		resChan <- result{res, err}
+	}()
+
+	return await(ctx, resChan)
+}
+
+// PoolExecutor runs operations on a fixed number of worker goroutines,
+// bounding the goroutines of the circuit breakers sharing it. Operations
+// wait for a free worker within the timeout of their call, failing with
+// `ErrExecutorSaturated` if there is none, or with `ctx.Err()` if the caller
+// gives up first. Saturation of the pool isn't held against the dependency,
+// such calls count as rejections. Operations which
+// don't return after their call timed out keep occupying the worker.
+type PoolExecutor struct {
+	jobs chan job
+	done chan struct{}
+	once sync.Once
+	wg   sync.WaitGroup
+}
+
+// job is an operation waiting for a worker.
+type job struct {
+	fn      func() (any, error)
+	resChan chan result
+}
+
+// NewPoolExecutor starts `workers` worker goroutines, at least one. They run
+// until `Close` is called.
+func NewPoolExecutor(workers int) *PoolExecutor {
+	p := &PoolExecutor{
+		jobs: make(chan job),
+		done: make(chan struct{}),
+	}
+
+	for i := 0; i < max(workers, 1); i++ {
+		p.wg.Add(1)
+		go p.work()
+	}
+
+	return p
+}
+
+// work runs jobs until the executor is closed.
+func (p *PoolExecutor) work() {
+	defer p.wg.Done()
+
+	for {
+		select {
+		case <-p.done:
+			return
+		case j := <-p.jobs:
+			res, err := j.fn()
+			j.resChan <- result{res, err}
+		}
+	}
+}
+
+func (p *PoolExecutor) Execute(ctx context.Context, fn func() (any, error)) (any, error) {
+	j := job{fn: fn, resChan: make(chan result, 1)}
+
+	select {
+	case <-ctx.Done():
+		if errors.Is(context.Cause(ctx), ErrTimeout) {
+			// No worker freed up within the timeout of the call
+			return nil, ErrExecutorSaturated
+		}
+		return nil, ctx.Err()
+	case <-p.done:
+		return nil, ErrExecutorClosed
+	case p.jobs <- j:
+	}
+
+	return await(ctx, j.resChan)
+}
+
+// Close stops the workers once they complete operations in flight.
+func (p *PoolExecutor) Close() {
+	p.once.Do(func() {
+		close(p.done)
+	})
+	p.wg.Wait()
+}
+
+// await waits for the outcome of an operation until `ctx` is done.
+func await(ctx context.Context, resChan <-chan result) (any, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-resChan:
+		return res.value, res.err
+	}
+}
@@ -0,0 +1,202 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPoolExecutor(t *testing.T) {
+	pool := NewPoolExecutor(2)
+	defer pool.Close()
+	cb := NewCircuitBreaker(2, 1, time.Minute, 50*time.Millisecond, WithExecutor(pool))
+
+	res, err := cb.Call(makeService(0, 1, 0))
+	if res != "OK" || err != nil {
+		t.Errorf("pooled call should succeed, got `%v`, `%v`", res, err)
+	}
+
+	_, err = cb.Call(sleepingService(100 * time.Millisecond))
+	if !errors.Is(err, ErrTimeout) {
+		t.Errorf("pooled call should time out, got `%v`", err)
+	}
+
+	_, err = cb.Call(func() (any, error) {
+		panic("nil map")
+	})
+	if !errors.Is(err, ErrPanic) {
+		t.Errorf("panic should be recovered without losing the worker, got `%v`", err)
+	}
+	if cb.State() != StateOpen {
+		t.Errorf("pooled failures should open the breaker, got `%s`", cb.State())
+	}
+}
+
+func TestPoolExecutorBusy(t *testing.T) {
+	pool := NewPoolExecutor(1)
+	defer pool.Close()
+
+	release := make(chan struct{})
+	go pool.Execute(context.Background(), func() (any, error) {
+		<-release
+		return nil, nil
+	})
+	defer close(release)
+
+	// Waiting for the only worker is bounded by the call timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	time.Sleep(10 * time.Millisecond)
+	_, err := pool.Execute(ctx, func() (any, error) { return "OK", nil })
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("operation waiting for a worker should end with the caller's deadline, got `%v`", err)
+	}
+}
+
+func TestPoolExecutorSaturated(t *testing.T) {
+	pool := NewPoolExecutor(1)
+	defer pool.Close()
+	cb := NewCircuitBreaker(1, 1, time.Minute, 20*time.Millisecond, WithExecutor(pool))
+
+	release := make(chan struct{})
+	go pool.Execute(context.Background(), func() (any, error) {
+		<-release
+		return nil, nil
+	})
+	defer close(release)
+	time.Sleep(10 * time.Millisecond)
+
+	_, outcome, err := cb.CallDetailed(makeService(0, 1, 0))
+	if outcome != OutcomeSaturated || !errors.Is(err, ErrExecutorSaturated) {
+		t.Errorf("call finding no free worker should be reported as saturated, got `%s`, `%v`", outcome, err)
+	}
+
+	stats := cb.Stats()
+	if cb.State() != StateClosed || stats.Failures != 0 || stats.Rejections != 1 {
+		t.Errorf("saturated pool shouldn't count as failure, got `%s` with `%+v`", cb.State(), stats.Counts)
+	}
+}
+
+func TestPoolExecutorClosed(t *testing.T) {
+	pool := NewPoolExecutor(1)
+	pool.Close()
+
+	_, err := pool.Execute(context.Background(), func() (any, error) { return "OK", nil })
+	if !errors.Is(err, ErrExecutorClosed) {
+		t.Errorf("closed executor should reject operations, got `%v`", err)
+	}
+}
+
+// peakGoroutines runs `calls` concurrent slow calls through `cb` and returns
+// the number of goroutines they added while in flight.
+func peakGoroutines(cb *CircuitBreaker, calls int) int {
+	before := runtime.NumGoroutine()
+	release := make(chan struct{})
+
+	var started, done sync.WaitGroup
+	started.Add(calls)
+	done.Add(calls)
+	for i := 0; i < calls; i++ {
+		go func() {
+			defer done.Done()
+			started.Done()
+			cb.Call(func() (any, error) {
+				<-release
+				return "OK", nil
+			})
+		}()
+	}
+	started.Wait()
+	time.Sleep(20 * time.Millisecond)
+
+	peak := runtime.NumGoroutine() - before
+	close(release)
+	done.Wait()
+
+	return peak
+}
+
+func TestExecutorGoroutines(t *testing.T) {
+	const calls = 50
+
+	perCall := peakGoroutines(NewCircuitBreaker(calls, 1, time.Minute, time.Second), calls)
+
+	pool := NewPoolExecutor(4)
+	defer pool.Close()
+	pooled := peakGoroutines(NewCircuitBreaker(calls, 1, time.Minute, time.Second, WithExecutor(pool)), calls)
+
+	// Callers account for `calls` goroutines with both executors, a few
+	// goroutines of other tests may come and go meanwhile
+	if perCall < 2*calls-5 || pooled > calls+5 {
+		t.Errorf("pool should bound goroutines of operations, got %d per call and %d pooled", perCall, pooled)
+	}
+}
+
+func BenchmarkExecutorGoroutine(b *testing.B) {
+	cb := NewCircuitBreaker(1, 1, time.Minute, time.Second)
+	op := func() (any, error) { return "OK", nil }
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			cb.Call(op)
+		}
+	})
+}
+
+func BenchmarkExecutorPool(b *testing.B) {
+	pool := NewPoolExecutor(runtime.GOMAXPROCS(0))
+	defer pool.Close()
+	cb := NewCircuitBreaker(1, 1, time.Minute, time.Second, WithExecutor(pool))
+	op := func() (any, error) { return "OK", nil }
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			cb.Call(op)
+		}
+	})
+}
+
+func TestPoolExecutorCallerCanceled(t *testing.T) {
+	pool := NewPoolExecutor(1)
+	defer pool.Close()
+	cb := NewCircuitBreaker(1, 1, time.Minute, time.Second, WithExecutor(pool))
+
+	release := make(chan struct{})
+	go pool.Execute(context.Background(), func() (any, error) {
+		<-release
+		return nil, nil
+	})
+	defer close(release)
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	_, outcome, err := cb.call(ctx, "", makeService(0, 1, 0))
+	if outcome != OutcomeCanceled || !errors.Is(err, context.Canceled) {
+		t.Errorf("caller giving up on a worker should be reported as canceled, got `%s`, `%v`", outcome, err)
+	}
+	if cb.State() != StateClosed || cb.Stats().Failures != 0 {
+		t.Errorf("canceled call shouldn't count as failure, got `%s` with `%+v`", cb.State(), cb.Stats().Counts)
+	}
+}
+
+func TestPoolExecutorClosedNotFailure(t *testing.T) {
+	pool := NewPoolExecutor(1)
+	pool.Close()
+	cb := NewCircuitBreaker(1, 1, time.Minute, time.Second, WithExecutor(pool))
+
+	_, outcome, err := cb.CallDetailed(makeService(0, 1, 0))
+	if outcome != OutcomeSaturated || !errors.Is(err, ErrExecutorClosed) {
+		t.Errorf("call on a closed executor should be reported as not run, got `%s`, `%v`", outcome, err)
+	}
+
+	stats := cb.Stats()
+	if cb.State() != StateClosed || stats.Failures != 0 || stats.Rejections != 1 {
+		t.Errorf("closed executor shouldn't count as failure, got `%s` with `%+v`", cb.State(), stats.Counts)
+	}
+}
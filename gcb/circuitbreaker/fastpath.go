@@ -0,0 +1,49 @@
+package circuitbreaker
+
+import (
+	"context"
+	"time"
+)
+
+// publishRejectUntil records the time until which calls are rejected with
+// `ErrOpenState` regardless of anything else, so they skip the lock. It's
+// zero whenever a call has to take the lock to be decided, e.g. outside
+// `open` state, while held, with an idle reset to detect or a rate limit to
+// account for. Must be called with the lock held.
+func (cb *CircuitBreaker) publishRejectUntil() {
+	var until int64
+	eligible := cb.loadState() == StateOpen && !cb.disabled && !cb.shutdown && !cb.held &&
+		cb.healthProbe == nil && cb.idleReset <= 0 && cb.rateLimit <= 0 &&
+		(cb.maxConcurrent <= 0 || cb.inFlight < cb.maxConcurrent)
+	if eligible {
+		if left := cb.retryAfter(); left > 0 {
+			until = cb.clock.Now().Add(left).UnixNano()
+		}
+	}
+
+	if cb.rejectUntil.Load() != until {
+		cb.rejectUntil.Store(until)
+	}
+}
+
+// fastReject rejects the call without taking the lock if the breaker is
+// known to be `open` until later, returning `nil` otherwise. Tagged calls
+// always take the lock to be accounted under their tag.
+func (cb *CircuitBreaker) fastReject(ctx context.Context, tag string) error {
+	until := cb.rejectUntil.Load()
+	if until == 0 || tag != "" || cb.loadState() != StateOpen {
+		return nil
+	}
+
+	left := time.Duration(until - cb.clock.Now().UnixNano())
+	if left <= 0 {
+		return nil
+	}
+
+	cb.stats.fastRejections.Add(1)
+	err := &BreakerError{Name: cb.name, State: StateOpen, RetryAfter: left, Err: ErrOpenState}
+	cb.notifyReject(OutcomeRejectedOpen)
+	cb.observe(ctx, 0, err, OutcomeRejectedOpen)
+
+	return err
+}
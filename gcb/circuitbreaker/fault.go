@@ -0,0 +1,35 @@
+package circuitbreaker
+
+// WithFaultInjector consults `fn` before every operation for chaos testing.
+// While injection is on and `fn` returns an error, the operation doesn't run
+// and the call fails with that error, counted as any other failure.
+// Injection is on from the start, see `SetFaultInjection`.
+func WithFaultInjector(fn func() error) Option {
+	return func(cb *CircuitBreaker) {
+		cb.faultInjector = fn
+		cb.injectingFaults.Store(fn != nil)
+	}
+}
+
+// SetFaultInjection turns injection of faults by the injector set with
+// `WithFaultInjector` on or off at runtime.
+func (cb *CircuitBreaker) SetFaultInjection(on bool) {
+	cb.injectingFaults.Store(on && cb.faultInjector != nil)
+}
+
+// injectFaults returns `fn` failing with injected faults.
+func (cb *CircuitBreaker) injectFaults(fn operation) operation {
+	if cb.faultInjector == nil {
+		return fn
+	}
+
+	return func() (any, error) {
+		if cb.injectingFaults.Load() {
+			if err := cb.faultInjector(); err != nil {
+				return nil, err
+			}
+		}
+
+		return fn()
+	}
+}
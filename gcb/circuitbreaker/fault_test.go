@@ -0,0 +1,65 @@
+package circuitbreaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFaultInjection(t *testing.T) {
+	clock := NewFakeClock()
+	errInjected := errors.New("injected fault")
+	cb := NewCircuitBreaker(2, 1, time.Second, time.Second, WithClock(clock),
+		WithFaultInjector(func() error { return errInjected }))
+
+	executed := 0
+	op := func() (any, error) {
+		executed++
+		return "OK", nil
+	}
+
+	cb.SetFaultInjection(false)
+	if res, err := cb.Call(op); res != "OK" || err != nil {
+		t.Errorf("call shouldn't fail with injection off, got `%v`, `%v`", res, err)
+	}
+
+	cb.SetFaultInjection(true)
+	for i := 0; i < 2; i++ {
+		if _, err := cb.Call(op); !errors.Is(err, errInjected) {
+			t.Errorf("call should fail with the injected fault, got `%v`", err)
+		}
+	}
+	if executed != 1 {
+		t.Errorf("operation shouldn't run while faults are injected, ran %d times", executed)
+	}
+	if cb.State() != StateOpen {
+		t.Fatalf("injected faults should open the breaker, got `%s`", cb.State())
+	}
+
+	cb.SetFaultInjection(false)
+	clock.Advance(2 * time.Second)
+	// Transition to half open, successful probe closes the breaker
+	cb.Call(op)
+	cb.Call(op)
+	if cb.State() != StateClosed {
+		t.Errorf("breaker should recover once injection is off, got `%s`", cb.State())
+	}
+}
+
+func TestFaultInjectorSelective(t *testing.T) {
+	calls := 0
+	cb := NewCircuitBreaker(5, 1, time.Second, time.Second,
+		WithFaultInjector(func() error {
+			calls++
+			// Every other call fails
+			if calls%2 == 0 {
+				return errors.New("injected fault")
+			}
+			return nil
+		}))
+
+	callOutcomes(cb, true, true, true, true)
+	if failures := cb.Stats().Failures; failures != 2 {
+		t.Errorf("only injected faults should fail, got %d failures", failures)
+	}
+}
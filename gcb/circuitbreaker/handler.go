@@ -0,0 +1,42 @@
+package circuitbreaker
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Handler serves snapshots of the breakers in `reg` as JSON, meant for
+// demos and debugging:
+//   - `GET /`: object of all snapshots keyed by breaker name
+//   - `GET /{name}`: snapshot of a single breaker, 404 if it's not registered
+func Handler(reg *Registry) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, reg.Snapshots())
+	})
+
+	mux.HandleFunc("GET /{name}", func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+
+		cb, ok := reg.Get(name)
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{
+				"error": fmt.Sprintf("breaker %q not found", name),
+			})
+			return
+		}
+
+		writeJSON(w, http.StatusOK, cb.Snapshot())
+	})
+
+	return mux
+}
+
+// writeJSON responds with `v` encoded as JSON.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,87 @@
+package circuitbreaker
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func serveDashboard(t *testing.T, method, path string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	reg := NewRegistry(registryDefaults)
+	reg.GetOrCreate("auth")
+	reg.GetOrCreate("payments").Call(makeService(1, 2, 100))
+
+	rec := httptest.NewRecorder()
+	Handler(reg).ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+
+	return rec
+}
+
+func TestHandlerList(t *testing.T) {
+	rec := serveDashboard(t, http.MethodGet, "/")
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status should be `%d`, got `%d`", http.StatusOK, rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("content type should be JSON, got `%s`", ct)
+	}
+
+	var body map[string]map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("body should be JSON, got `%v`: %s", err, rec.Body)
+	}
+
+	if len(body) != 2 {
+		t.Errorf("body should list 2 breakers, got `%v`", body)
+	}
+	if s := body["auth"]["state"]; s != "closed" {
+		t.Errorf("`auth` should be closed, got `%v`", s)
+	}
+	if s := body["payments"]["state"]; s != "open" {
+		t.Errorf("`payments` should be open, got `%v`", s)
+	}
+}
+
+func TestHandlerSingle(t *testing.T) {
+	rec := serveDashboard(t, http.MethodGet, "/payments")
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status should be `%d`, got `%d`", http.StatusOK, rec.Code)
+	}
+
+	var body map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("body should be JSON, got `%v`: %s", err, rec.Body)
+	}
+
+	if body["state"] != "open" || body["failure_count"] != 1.0 {
+		t.Errorf("body should be the open snapshot, got `%v`", body)
+	}
+	if _, ok := body["last_failure_time"]; !ok {
+		t.Errorf("body should report the last failure, got `%v`", body)
+	}
+}
+
+func TestHandlerUnknown(t *testing.T) {
+	rec := serveDashboard(t, http.MethodGet, "/billing")
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status should be `%d`, got `%d`", http.StatusNotFound, rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), `billing`) {
+		t.Errorf("error should name the breaker, got `%s`", rec.Body)
+	}
+}
+
+func TestHandlerMethodNotAllowed(t *testing.T) {
+	rec := serveDashboard(t, http.MethodPost, "/payments")
+
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("status should be `%d`, got `%d`", http.StatusMethodNotAllowed, rec.Code)
+	}
+}
@@ -0,0 +1,56 @@
+package circuitbreaker
+
+import "time"
+
+// WithHealthProbe lets `probe` decide when the breaker in `open` state may
+// attempt recovery. While open the probe runs every `interval` on the clock,
+// the breaker transitions to `half-open` once it reports healthy, but not
+// before the recovery time passes. Until then all requests are rejected.
+func WithHealthProbe(probe func() bool, interval time.Duration) Option {
+	return func(cb *CircuitBreaker) {
+		cb.healthProbe = probe
+		cb.healthProbeInterval = interval
+	}
+}
+
+// scheduleHealthProbe schedules the next health check of the `open` state of
+// `generation` on the clock.
+func (cb *CircuitBreaker) scheduleHealthProbe(generation uint64) {
+	cb.wg.Add(1)
+	cb.healthTimer = cb.clock.AfterFunc(cb.healthProbeInterval, func() {
+		defer cb.wg.Done()
+		cb.checkHealth(generation)
+	})
+}
+
+// checkHealth runs the health probe while the breaker stays in the `open`
+// state of `generation`, transitioning to `half-open` once healthy or
+// scheduling the next check otherwise.
+func (cb *CircuitBreaker) checkHealth(generation uint64) {
+	cb.mu.Lock()
+	if cb.generation != generation || cb.shutdown {
+		cb.unlock()
+		return
+	}
+	cb.healthTimer = nil
+	cb.unlock()
+
+	// Probe runs without holding the lock
+	healthy := cb.healthProbe()
+
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	if cb.generation != generation || cb.shutdown {
+		return
+	}
+
+	if healthy && cb.clock.Now().Sub(cb.lastFailureTime) >= cb.cycleRecoveryTime {
+		// Passing probe is the signal a held breaker waits for
+		cb.held = false
+		cb.toHalfOpen()
+		return
+	}
+
+	cb.scheduleHealthProbe(generation)
+}
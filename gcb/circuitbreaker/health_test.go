@@ -0,0 +1,113 @@
+package circuitbreaker
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHealthProbeGovernsRecovery(t *testing.T) {
+	clock := NewFakeClock()
+
+	var probes atomic.Int32
+	probe := func() bool {
+		// Unhealthy for the first three checks
+		return probes.Add(1) > 3
+	}
+
+	cb := NewCircuitBreaker(1, 1, time.Second, time.Second,
+		WithClock(clock), WithHealthProbe(probe, 5*time.Millisecond))
+	defer cb.Close()
+
+	callOutcomes(cb, false)
+	clock.Advance(2 * time.Second)
+
+	// Recovery time passed, yet calls are rejected while the probe fails
+	callOutcomes(cb, true)
+	if cb.State() != StateOpen {
+		t.Fatalf("breaker should stay open while unhealthy, got `%s`", cb.State())
+	}
+
+	for range 3 {
+		clock.Advance(5 * time.Millisecond)
+	}
+	if cb.State() != StateHalfOpen {
+		t.Fatalf("breaker should move to half open once healthy, got `%s`", cb.State())
+	}
+
+	if n := probes.Load(); n != 4 {
+		t.Errorf("transition should happen on the first healthy check, got `%d` checks", n)
+	}
+}
+
+func TestHealthProbeRunsOnClock(t *testing.T) {
+	clock := NewFakeClock()
+
+	var probes atomic.Int32
+	probe := func() bool {
+		probes.Add(1)
+		return false
+	}
+
+	cb := NewCircuitBreaker(1, 1, time.Second, time.Second,
+		WithClock(clock), WithHealthProbe(probe, 10*time.Millisecond))
+	defer cb.Close()
+
+	callOutcomes(cb, false)
+	if n := probes.Load(); n != 0 {
+		t.Fatalf("probe shouldn't run before the interval passes, got `%d` checks", n)
+	}
+
+	for range 5 {
+		clock.Advance(10 * time.Millisecond)
+	}
+	if n := probes.Load(); n != 5 {
+		t.Errorf("probe should run once per interval, got `%d` checks", n)
+	}
+}
+
+func TestHealthProbeRespectsRecoveryTime(t *testing.T) {
+	clock := NewFakeClock()
+	alwaysHealthy := func() bool { return true }
+
+	cb := NewCircuitBreaker(1, 1, time.Second, time.Second,
+		WithClock(clock), WithHealthProbe(alwaysHealthy, 5*time.Millisecond))
+	defer cb.Close()
+
+	callOutcomes(cb, false)
+
+	clock.Advance(5 * time.Millisecond)
+	if cb.State() != StateOpen {
+		t.Fatalf("breaker shouldn't recover before recovery time")
+	}
+
+	clock.Advance(time.Second)
+	if cb.State() != StateHalfOpen {
+		t.Errorf("breaker should move to half open after recovery time, got `%s`", cb.State())
+	}
+}
+
+func TestHealthProbeStopsOnClose(t *testing.T) {
+	clock := NewFakeClock()
+
+	var probes atomic.Int32
+	probe := func() bool {
+		probes.Add(1)
+		return false
+	}
+
+	cb := NewCircuitBreaker(1, 1, time.Second, time.Second,
+		WithClock(clock), WithHealthProbe(probe, 5*time.Millisecond))
+	callOutcomes(cb, false)
+	clock.Advance(5 * time.Millisecond)
+	cb.Close()
+
+	if n := clock.Pending(); n != 0 {
+		t.Errorf("close should cancel the scheduled check, got `%d` pending", n)
+	}
+
+	clock.Advance(time.Second)
+	if n := probes.Load(); n != 1 {
+		t.Errorf("health probe should stop after close, got `%d` checks", n)
+	}
+}
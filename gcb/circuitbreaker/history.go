@@ -0,0 +1,70 @@
+package circuitbreaker
+
+import "time"
+
+// CallRecord describes a single executed operation.
+type CallRecord struct {
+	// Time the operation started
+	At time.Time
+	// Time it took the operation to complete or time out
+	Duration time.Duration
+	// Error returned by the operation, `nil` on success
+	Err error
+}
+
+// callHistory is a fixed size ring buffer of the most recent call records.
+type callHistory struct {
+	records []CallRecord
+	// Index where the next record is written
+	next int
+	// Number of records written, capped at capacity
+	size int
+}
+
+func newCallHistory(n int) *callHistory {
+	return &callHistory{records: make([]CallRecord, n)}
+}
+
+func (h *callHistory) add(r CallRecord) {
+	h.records[h.next] = r
+	h.next = (h.next + 1) % len(h.records)
+	if h.size < len(h.records) {
+		h.size++
+	}
+}
+
+// list returns a copy of the records ordered from the oldest to the newest.
+func (h *callHistory) list() []CallRecord {
+	out := make([]CallRecord, 0, h.size)
+	start := (h.next - h.size + len(h.records)) % len(h.records)
+	for i := 0; i < h.size; i++ {
+		out = append(out, h.records[(start+i)%len(h.records)])
+	}
+
+	return out
+}
+
+// WithHistory keeps the outcomes of the last `n` executed calls, available
+// through `History`. Values below 1 disable the history.
+func WithHistory(n int) Option {
+	return func(cb *CircuitBreaker) {
+		if n < 1 {
+			cb.history = nil
+			return
+		}
+		cb.history = newCallHistory(n)
+	}
+}
+
+// History returns the recorded outcomes ordered from the oldest to the
+// newest, `nil` if the history is not enabled.
+func (cb *CircuitBreaker) History() []CallRecord {
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	if cb.history == nil {
+		return nil
+	}
+
+	return cb.history.list()
+}
@@ -0,0 +1,82 @@
+package circuitbreaker
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestHistoryKeepsMostRecent(t *testing.T) {
+	cb := NewCircuitBreaker(10, 1, 2*time.Second, 2*time.Second, WithHistory(3))
+
+	for i := 0; i < 5; i++ {
+		cb.Call(func() (any, error) {
+			return nil, fmt.Errorf("failure %d", i)
+		})
+	}
+
+	history := cb.History()
+	if len(history) != 3 {
+		t.Fatalf("history should keep 3 records, got `%d`", len(history))
+	}
+
+	for i, record := range history {
+		want := fmt.Sprintf("failure %d", i+2)
+		if record.Err == nil || record.Err.Error() != want {
+			t.Errorf("record %d should hold `%s`, got `%v`", i, want, record.Err)
+		}
+	}
+}
+
+func TestHistoryRecordsDurationAndError(t *testing.T) {
+	cb := NewCircuitBreaker(10, 1, 2*time.Second, 50*time.Millisecond, WithHistory(5))
+	failure := errors.New("service failed")
+
+	before := time.Now()
+	cb.Call(func() (any, error) {
+		time.Sleep(20 * time.Millisecond)
+		return "OK", nil
+	})
+	cb.Call(func() (any, error) {
+		return nil, failure
+	})
+	cb.Call(func() (any, error) {
+		time.Sleep(200 * time.Millisecond)
+		return "OK", nil
+	})
+
+	history := cb.History()
+	if len(history) != 3 {
+		t.Fatalf("history should keep 3 records, got `%d`", len(history))
+	}
+
+	if history[0].Err != nil || history[0].Duration < 20*time.Millisecond {
+		t.Errorf("first record should be a 20ms success, got `%+v`", history[0])
+	}
+
+	if history[0].At.Before(before) {
+		t.Errorf("record start time should be after test start, got `%s`", history[0].At)
+	}
+
+	if !errors.Is(history[1].Err, failure) {
+		t.Errorf("second record should hold service error, got `%v`", history[1].Err)
+	}
+
+	if !errors.Is(history[2].Err, ErrTimeout) {
+		t.Errorf("third record should hold timeout error, got `%v`", history[2].Err)
+	}
+
+	if d := history[2].Duration; d < 50*time.Millisecond || d > 200*time.Millisecond {
+		t.Errorf("timed out record should last about the timeout, got `%s`", d)
+	}
+}
+
+func TestHistoryDisabled(t *testing.T) {
+	cb := NewCircuitBreaker(10, 1, 2*time.Second, 2*time.Second)
+	cb.Call(makeService(20, 50, 0))
+
+	if history := cb.History(); history != nil {
+		t.Errorf("history should be nil when disabled, got `%v`", history)
+	}
+}
@@ -0,0 +1,72 @@
+package circuitbreaker
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+// HTTPMiddleware protects handlers of a server with `cb`, e.g. shedding load
+// while a dependency they need is down. Requests the breaker rejects get `503
+// Service Unavailable` right away, with `Retry-After` when the rejection tells
+// when to retry, e.g. time left in `open` state or until the rate limit
+// allows another call. Admitted requests are accounted like calls, handlers
+// responding with a 5xx status or panicking count as failures.
+//
+// The breaker timeout doesn't apply to handlers, a slow response is accounted
+// by its status only. To bound handlers wrap them with `http.TimeoutHandler`
+// inside the middleware, its 503 responses count as failures.
+func HTTPMiddleware(cb *CircuitBreaker) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			permit, err := cb.try(false)
+			if permit == nil {
+				var be *BreakerError
+				if errors.As(err, &be) && be.RetryAfter > 0 {
+					w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(be.RetryAfter.Seconds()))))
+				}
+				http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+				return
+			}
+
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			completed := false
+			defer func() {
+				// Panic propagates to the server once accounted for
+				if !completed {
+					permit(cb.errorf("%w", ErrPanic))
+				}
+			}()
+
+			next.ServeHTTP(rec, r)
+			completed = true
+
+			if rec.status >= http.StatusInternalServerError {
+				permit(cb.errorf("handler responded with status %d", rec.status))
+				return
+			}
+			permit(nil)
+		})
+	}
+}
+
+// statusRecorder captures the status code of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap exposes the underlying writer to `http.ResponseController`.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,106 @@
+package circuitbreaker
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// serveProtected makes a request to `handler` protected by `cb`.
+func serveProtected(cb *CircuitBreaker, handler http.HandlerFunc) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	HTTPMiddleware(cb)(handler).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/orders", nil))
+	return rec
+}
+
+func TestHTTPMiddleware(t *testing.T) {
+	cb := NewCircuitBreaker(2, 1, time.Minute, time.Second)
+
+	rec := serveProtected(cb, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	})
+	if rec.Code != http.StatusOK || rec.Body.String() != "OK" {
+		t.Errorf("closed breaker should pass the request through, got %d `%s`", rec.Code, rec.Body)
+	}
+
+	// Client errors aren't failures of the server
+	serveProtected(cb, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	if stats := cb.Stats(); stats.Successes != 2 {
+		t.Errorf("non 5xx responses should count as successes, got `%+v`", stats.Counts)
+	}
+}
+
+func TestHTTPMiddlewareOpen(t *testing.T) {
+	cb := NewCircuitBreaker(2, 1, 90*time.Second, time.Second)
+	failing := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}
+	serveProtected(cb, failing)
+	serveProtected(cb, failing)
+	if cb.State() != StateOpen {
+		t.Fatalf("5xx responses should open the breaker, got `%s`", cb.State())
+	}
+
+	served := false
+	rec := serveProtected(cb, func(w http.ResponseWriter, r *http.Request) {
+		served = true
+	})
+	if served || rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("open breaker should respond with 503 right away, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "90" {
+		t.Errorf("response should tell when to retry, got `%s`", got)
+	}
+}
+
+func TestHTTPMiddlewarePanic(t *testing.T) {
+	cb := NewCircuitBreaker(1, 1, time.Minute, time.Second)
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("panic should propagate to the server")
+			}
+		}()
+		serveProtected(cb, func(w http.ResponseWriter, r *http.Request) {
+			panic("nil map")
+		})
+	}()
+
+	if cb.State() != StateOpen {
+		t.Errorf("panicking handler should count as failure, got `%s`", cb.State())
+	}
+}
+
+func TestHTTPMiddlewareSlowHandler(t *testing.T) {
+	cb := NewCircuitBreaker(1, 1, time.Minute, 20*time.Millisecond)
+
+	rec := serveProtected(cb, func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte("OK"))
+	})
+	if rec.Code != http.StatusOK {
+		t.Errorf("slow handler should respond, got %d", rec.Code)
+	}
+	if stats := cb.Stats(); cb.State() != StateClosed || stats.Successes != 1 {
+		t.Errorf("slow successful response shouldn't count as failure, got `%s` with `%+v`", cb.State(), stats.FailuresByReason)
+	}
+}
+
+func TestHTTPMiddlewareRateLimited(t *testing.T) {
+	clock := NewFakeClock()
+	cb := NewCircuitBreaker(1, 1, time.Minute, time.Second, WithClock(clock), WithRateLimit(0.5, 1))
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+
+	serveProtected(cb, ok)
+	rec := serveProtected(cb, ok)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("rate limited request should get 503, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("response should tell when the next token is available, got `%s`", got)
+	}
+}
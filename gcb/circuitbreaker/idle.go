@@ -0,0 +1,39 @@
+package circuitbreaker
+
+import (
+	"log/slog"
+	"time"
+)
+
+// WithResetAfterIdle resets the circuit breaker to `closed` state with
+// cleared counters when no call was made for longer than `d`, so traffic
+// resuming after a quiet period doesn't inherit an outdated state. The reset
+// happens on the next call, held breakers are never reset.
+func WithResetAfterIdle(d time.Duration) Option {
+	return func(cb *CircuitBreaker) {
+		cb.idleReset = d
+	}
+}
+
+// resetIfIdle resets the circuit breaker if it's been idle for longer than
+// allowed and marks the time of the call unless `dry`. Must be called with
+// the lock held.
+func (cb *CircuitBreaker) resetIfIdle(dry bool) {
+	if cb.idleReset <= 0 {
+		return
+	}
+
+	now := cb.clock.Now()
+	idle := !cb.lastCallTime.IsZero() && now.Sub(cb.lastCallTime) > cb.idleReset
+	if !dry {
+		cb.lastCallTime = now
+	}
+
+	// Operation still in flight means the breaker wasn't idle
+	if !idle || cb.held || cb.inFlight > 0 {
+		return
+	}
+
+	slog.Info("resetting idle breaker", "name", cb.name, "state", cb.loadState())
+	cb.reset()
+}
@@ -0,0 +1,86 @@
+package circuitbreaker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResetAfterIdle(t *testing.T) {
+	clock := NewFakeClock()
+	cb := NewCircuitBreaker(1, 1, time.Hour, time.Second, WithClock(clock), WithResetAfterIdle(10*time.Minute))
+
+	cb.Call(makeService(0, 1, 100))
+	if cb.State() != StateOpen {
+		t.Fatalf("failure should open the breaker, got `%s`", cb.State())
+	}
+
+	clock.Advance(11 * time.Minute)
+	_, outcome, err := cb.CallDetailed(makeService(0, 1, 0))
+	if outcome != OutcomeExecuted || err != nil {
+		t.Errorf("call after idle period should be executed, got `%s`, `%v`", outcome, err)
+	}
+	if cb.State() != StateClosed || cb.failureCount != 0 || !cb.lastFailureTime.IsZero() {
+		t.Errorf("breaker should start from a clean closed state, got `%s` with %d failures", cb.State(), cb.failureCount)
+	}
+}
+
+func TestResetAfterIdleClearsCounters(t *testing.T) {
+	clock := NewFakeClock()
+	cb := NewCircuitBreaker(2, 1, time.Hour, time.Second, WithClock(clock), WithResetAfterIdle(10*time.Minute))
+
+	cb.Call(makeService(0, 1, 100))
+	clock.Advance(11 * time.Minute)
+	cb.Call(makeService(0, 1, 100))
+
+	if cb.State() != StateClosed {
+		t.Errorf("failure before idle period shouldn't count, got `%s`", cb.State())
+	}
+}
+
+func TestResetAfterIdleWithTraffic(t *testing.T) {
+	clock := NewFakeClock()
+	cb := NewCircuitBreaker(1, 1, time.Hour, time.Second, WithClock(clock), WithResetAfterIdle(10*time.Minute))
+
+	cb.Call(makeService(0, 1, 100))
+	// Rejected calls keep the breaker from being idle
+	for i := 0; i < 3; i++ {
+		clock.Advance(6 * time.Minute)
+		if _, err := cb.Call(makeService(0, 1, 0)); err == nil {
+			t.Fatalf("breaker with traffic shouldn't reset")
+		}
+	}
+}
+
+func TestResetAfterIdleHeld(t *testing.T) {
+	clock := NewFakeClock()
+	cb := NewCircuitBreaker(1, 1, time.Hour, time.Second, WithClock(clock), WithResetAfterIdle(10*time.Minute))
+
+	cb.Call(makeService(0, 1, 0))
+	cb.Hold()
+	clock.Advance(11 * time.Minute)
+	cb.Call(makeService(0, 1, 0))
+
+	if cb.State() != StateOpen {
+		t.Errorf("held breaker shouldn't reset, got `%s`", cb.State())
+	}
+}
+
+func TestAllowRequestAfterIdle(t *testing.T) {
+	clock := NewFakeClock()
+	cb := NewCircuitBreaker(1, 1, time.Hour, time.Second, WithClock(clock), WithResetAfterIdle(10*time.Minute))
+
+	cb.Call(makeService(0, 1, 100))
+	clock.Advance(6 * time.Minute)
+	if cb.AllowRequest() {
+		t.Fatal("open breaker shouldn't allow requests before the idle period passes")
+	}
+
+	// Asking doesn't count as a call keeping the breaker from being idle
+	clock.Advance(6 * time.Minute)
+	if !cb.AllowRequest() {
+		t.Error("idle breaker should allow requests")
+	}
+	if cb.State() != StateClosed {
+		t.Errorf("idle breaker should reset as a call would, got `%s`", cb.State())
+	}
+}
@@ -0,0 +1,26 @@
+package circuitbreaker
+
+// Middleware decorates the operation of a call, e.g. with logging, metrics or
+// retries. It returns the operation to run in place of `next`.
+type Middleware func(next operation) operation
+
+// Use adds middlewares wrapping the operation of every subsequent call. They
+// run in the order they were added, the first one outermost, and the breaker
+// accounts for the outcome returned by the outermost one. Middlewares run
+// within the timeout of the call, rejected calls don't reach them.
+func (cb *CircuitBreaker) Use(mw ...Middleware) {
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	// Calls in flight keep the chain they were admitted with
+	cb.middlewares = append(cb.middlewares[:len(cb.middlewares):len(cb.middlewares)], mw...)
+}
+
+// chain wraps `fn` with middlewares, the first one outermost.
+func chain(middlewares []Middleware, fn operation) operation {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		fn = middlewares[i](fn)
+	}
+
+	return fn
+}
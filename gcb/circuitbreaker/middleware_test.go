@@ -0,0 +1,77 @@
+package circuitbreaker
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestUse(t *testing.T) {
+	cb := NewCircuitBreaker(1, 1, time.Minute, time.Second)
+
+	var trace []string
+	logging := func(next operation) operation {
+		return func() (any, error) {
+			trace = append(trace, "logging")
+			res, err := next()
+			trace = append(trace, fmt.Sprintf("logged `%v`", err))
+			return res, err
+		}
+	}
+	// Turns the error into a success
+	ignoring := func(next operation) operation {
+		return func() (any, error) {
+			trace = append(trace, "ignoring")
+			next()
+			return "fallback", nil
+		}
+	}
+	cb.Use(logging, ignoring)
+
+	res, err := cb.Call(func() (any, error) {
+		trace = append(trace, "operation")
+		return nil, errors.New("not found")
+	})
+
+	want := []string{"logging", "ignoring", "operation", "logged `<nil>`"}
+	if !slices.Equal(trace, want) {
+		t.Errorf("middlewares should run in order `%v`, got `%v`", want, trace)
+	}
+	if res != "fallback" || err != nil {
+		t.Errorf("call should return the outcome of the middlewares, got `%v`, `%v`", res, err)
+	}
+	if cb.State() != StateClosed {
+		t.Errorf("breaker should see the transformed outcome, got `%s`", cb.State())
+	}
+}
+
+func TestUseFailure(t *testing.T) {
+	cb := NewCircuitBreaker(1, 1, time.Minute, time.Second)
+
+	errUpstream := errors.New("upstream unavailable")
+	cb.Use(func(next operation) operation {
+		return func() (any, error) {
+			res, err := next()
+			if err != nil {
+				return res, fmt.Errorf("%w: %w", errUpstream, err)
+			}
+			return res, nil
+		}
+	})
+
+	_, err := cb.Call(makeService(0, 1, 100))
+	if !errors.Is(err, errUpstream) {
+		t.Errorf("call should return the error of the middleware, got `%v`", err)
+	}
+	if cb.State() != StateOpen {
+		t.Errorf("breaker should count the transformed failure, got `%s`", cb.State())
+	}
+
+	// Rejected calls don't reach middlewares
+	_, err = cb.Call(makeService(0, 1, 0))
+	if !errors.Is(err, ErrOpenState) || errors.Is(err, errUpstream) {
+		t.Errorf("rejected call should skip middlewares, got `%v`", err)
+	}
+}
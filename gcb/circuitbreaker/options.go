@@ -0,0 +1,154 @@
+package circuitbreaker
+
+import "time"
+
+// defaultName is used for breakers created without `WithName`
+const defaultName = "default"
+
+// Option configures optional behavior of the circuit breaker.
+type Option func(*CircuitBreaker)
+
+// WithName sets the name reported in logs and errors of the circuit breaker.
+func WithName(name string) Option {
+	return func(cb *CircuitBreaker) {
+		cb.name = name
+	}
+}
+
+// WithFailureWeight assigns a score to each failure, the breaker opens once the
+// score of consecutive failures reaches the failure threshold. By default
+// every failure scores 1.
+func WithFailureWeight(weight func(err error) int) Option {
+	return func(cb *CircuitBreaker) {
+		cb.failureWeight = weight
+	}
+}
+
+// WithHalfOpenQueue lets up to `maxWaiters` callers wait at most `maxWait`
+// for the `half-open` probe slot instead of being rejected right away.
+// Callers beyond `maxWaiters` and those not served in time are rejected with
+// `ErrTooManyRequests`. The wait is measured on the breaker clock and never
+// outlasts the caller's context, whose error is returned once it's done.
+func WithHalfOpenQueue(maxWaiters int, maxWait time.Duration) Option {
+	return func(cb *CircuitBreaker) {
+		cb.halfOpenMaxWaiters = maxWaiters
+		cb.halfOpenMaxWait = maxWait
+	}
+}
+
+// WithHalfOpenSuccessRate decides recovery by the success rate of `half-open`
+// requests instead of consecutive successes. Failures don't re-open the
+// breaker right away, after `minCalls` requests it closes if at least `rate`
+// of them succeeded and re-opens otherwise. It re-opens early once `rate`
+// can no longer be met.
+func WithHalfOpenSuccessRate(minCalls int, rate float64) Option {
+	return func(cb *CircuitBreaker) {
+		cb.halfOpenMinCalls = minCalls
+		cb.halfOpenSuccessRate = rate
+	}
+}
+
+// WithHalfOpenConsecutive decides whether `half-open` recovery requires
+// consecutive successes, which is the default, with the first failed probe
+// re-opening the breaker. Otherwise successes are cumulative, the breaker
+// closes once `halfOpenThreshold` probes succeeded and re-opens only after
+// more than `halfOpenThreshold` probes failed. Ignored when recovery is
+// decided by `WithHalfOpenSuccessRate`.
+func WithHalfOpenConsecutive(consecutive bool) Option {
+	return func(cb *CircuitBreaker) {
+		cb.halfOpenCumulative = !consecutive
+	}
+}
+
+// WithMaxConcurrent limits the number of operations executing through the
+// breaker at once to `n`, calls beyond the limit are rejected with
+// `ErrBulkheadFull` regardless of the state.
+func WithMaxConcurrent(n int) Option {
+	return func(cb *CircuitBreaker) {
+		cb.maxConcurrent = n
+	}
+}
+
+// WithFailureTTL stops counting failures towards opening the breaker once
+// they are older than `ttl`, so only recent failures can trip it.
+func WithFailureTTL(ttl time.Duration) Option {
+	return func(cb *CircuitBreaker) {
+		cb.failureTTL = ttl
+	}
+}
+
+// WithImmediateOpenErrors opens the breaker on a single failure matched by
+// `predicate`, bypassing the failure threshold. Useful for errors which won't
+// go away by retrying, e.g. revoked credentials.
+func WithImmediateOpenErrors(predicate func(err error) bool) Option {
+	return func(cb *CircuitBreaker) {
+		cb.immediateOpen = predicate
+	}
+}
+
+// WithHalfOpenMaxLatency requires `half-open` probes to complete within `d` to
+// count towards recovery, successful but slower probes are not counted.
+func WithHalfOpenMaxLatency(d time.Duration) Option {
+	return func(cb *CircuitBreaker) {
+		cb.halfOpenMaxLatency = d
+	}
+}
+
+// WithSlowProbeReopen transitions the breaker back to `open` state when a
+// probe is slower than allowed by `WithHalfOpenMaxLatency`.
+func WithSlowProbeReopen(reopen bool) Option {
+	return func(cb *CircuitBreaker) {
+		cb.slowProbeReopens = reopen
+	}
+}
+
+// WithProbeFailuresInWindow counts failed `half-open` probes towards opening
+// the breaker once it closes again, subject to `WithFailureTTL`. By default
+// the breaker closes with a clean slate, so a failed probe can't contribute
+// to re-opening a freshly closed breaker.
+func WithProbeFailuresInWindow(record bool) Option {
+	return func(cb *CircuitBreaker) {
+		cb.probeFailuresInWindow = record
+	}
+}
+
+// WithHalfOpenTrafficPercent admits each `half-open` request with probability
+// `p` instead of a single probe at a time, the rest are rejected with
+// `ErrTooManyRequests`. Admitted requests run concurrently and decide
+// recovery as probes do, combine with `WithHalfOpenSuccessRate` to decide by
+// the success rate of the sample.
+func WithHalfOpenTrafficPercent(p float64) Option {
+	return func(cb *CircuitBreaker) {
+		cb.halfOpenTrafficPercent = p
+	}
+}
+
+// WithRecoveryJitter randomizes the recovery time by up to `fraction` of it
+// in either direction, drawn anew each time the breaker opens. It keeps
+// breakers of many instances which tripped at once from retrying the
+// dependency at the same moment.
+func WithRecoveryJitter(fraction float64) Option {
+	return func(cb *CircuitBreaker) {
+		cb.recoveryJitter = fraction
+	}
+}
+
+// WithTimeoutThreshold opens the breaker on `n` consecutive timeouts
+// regardless of the failure threshold, timeouts still count as failures as
+// well. Useful to trip faster when the dependency is overloaded than on
+// functional errors.
+func WithTimeoutThreshold(n int) Option {
+	return func(cb *CircuitBreaker) {
+		cb.timeoutThreshold = n
+	}
+}
+
+// WithResultOnError decides what callers get when the operation returns a
+// result along with an error. By default both are passed through, so the
+// result can carry details of the failure, e.g. a retry hint. With
+// `passThrough` set to false the error wins and the result is `nil`.
+func WithResultOnError(passThrough bool) Option {
+	return func(cb *CircuitBreaker) {
+		cb.dropResultOnError = !passThrough
+	}
+}
@@ -0,0 +1,872 @@
+package circuitbreaker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// captureLogs redirects the default logger into a buffer for the duration of
+// the test.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	return buf
+}
+
+func TestWithNameError(t *testing.T) {
+	cb := NewCircuitBreaker(1, 1, 2*time.Second, 2*time.Second, WithName("payments"))
+	// Always failing service
+	s := makeService(20, 200, 100)
+
+	cb.Call(s)
+	_, err := cb.Call(s)
+
+	want := `breaker "payments": open state; request blocked`
+	if err == nil || err.Error() != want {
+		t.Errorf("error should be `%s`, got `%v`", want, err)
+	}
+}
+
+func TestDefaultName(t *testing.T) {
+	cb := NewCircuitBreaker(1, 1, 2*time.Second, 2*time.Second)
+	// Always failing service
+	s := makeService(20, 200, 100)
+
+	cb.Call(s)
+	_, err := cb.Call(s)
+
+	if err == nil || !strings.HasPrefix(err.Error(), `breaker "default": `) {
+		t.Errorf("error should be prefixed with the default name, got `%v`", err)
+	}
+}
+
+func TestWithNameLogs(t *testing.T) {
+	logs := captureLogs(t)
+
+	cb := NewCircuitBreaker(1, 1, 2*time.Second, 2*time.Second, WithName("payments"))
+	// Always failing service
+	cb.Call(makeService(20, 200, 100))
+
+	transitions := 0
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("log line should be JSON, got `%s`", line)
+		}
+
+		if entry["name"] != "payments" {
+			t.Errorf("log line should carry breaker name, got `%s`", line)
+		}
+
+		if strings.HasPrefix(entry["msg"].(string), "state transitioning") {
+			transitions++
+		}
+	}
+
+	if transitions != 1 {
+		t.Errorf("expected a single transition log line, got `%d`", transitions)
+	}
+}
+
+// timeoutWeight scores timeouts twice as much as other failures
+func timeoutWeight(err error) int {
+	if errors.Is(err, ErrTimeout) {
+		return 2
+	}
+	return 1
+}
+
+func TestWithFailureWeightTimeouts(t *testing.T) {
+	cb := NewCircuitBreaker(4, 1, 2*time.Second, 10*time.Millisecond, WithFailureWeight(timeoutWeight))
+	// Service slower than the timeout
+	s := makeService(50, 100, 0)
+
+	cb.Call(s)
+	if cb.State() != StateClosed {
+		t.Errorf("single timeout shouldn't open the breaker, got `%s`", cb.State())
+	}
+
+	cb.Call(s)
+	if cb.State() != StateOpen {
+		t.Errorf("two timeouts should open the breaker, got `%s`", cb.State())
+	}
+}
+
+func TestWithFailureWeightErrors(t *testing.T) {
+	cb := NewCircuitBreaker(4, 1, 2*time.Second, 2*time.Second, WithFailureWeight(timeoutWeight))
+	// Always failing service
+	s := makeService(20, 50, 100)
+
+	for i := 0; i < 3; i++ {
+		cb.Call(s)
+	}
+	if cb.State() != StateClosed {
+		t.Errorf("three errors shouldn't open the breaker, got `%s`", cb.State())
+	}
+
+	cb.Call(s)
+	if cb.State() != StateOpen {
+		t.Errorf("four errors should open the breaker, got `%s`", cb.State())
+	}
+}
+
+// blockingProbe returns an operation that blocks until `release` is closed
+func blockingProbe(release <-chan struct{}) operation {
+	return func() (any, error) {
+		<-release
+		return "OK", nil
+	}
+}
+
+func TestHalfOpenQueueWaiterGetsSlot(t *testing.T) {
+	cb := NewCircuitBreaker(1, 2, 20*time.Millisecond, 2*time.Second, WithHalfOpenQueue(1, time.Second))
+	makeHalfOpen(t, cb)
+
+	release := make(chan struct{})
+	probe := cb.CallAsync(blockingProbe(release))
+	// Let the probe take the slot
+	time.Sleep(10 * time.Millisecond)
+
+	waiter := cb.CallAsync(makeService(10, 20, 0))
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+
+	if res := <-probe; res.Err != nil {
+		t.Errorf("probe should succeed, got `%v`", res.Err)
+	}
+
+	if res := <-waiter; res.Err != nil || res.Value != "OK" {
+		t.Errorf("waiter should get the probe slot once it frees, got `%+v`", res)
+	}
+
+	if cb.State() != StateClosed {
+		t.Errorf("both probes succeeded, breaker should close, got `%s`", cb.State())
+	}
+}
+
+func TestHalfOpenQueueWaiterTimesOut(t *testing.T) {
+	cb := NewCircuitBreaker(1, 2, 20*time.Millisecond, 2*time.Second, WithHalfOpenQueue(1, 20*time.Millisecond))
+	makeHalfOpen(t, cb)
+
+	release := make(chan struct{})
+	defer close(release)
+	cb.CallAsync(blockingProbe(release))
+	time.Sleep(10 * time.Millisecond)
+
+	start := time.Now()
+	_, err := cb.Call(makeService(10, 20, 0))
+	if !errors.Is(err, ErrTooManyRequests) {
+		t.Errorf("waiter should be rejected after waiting, got `%v`", err)
+	}
+
+	if d := time.Since(start); d < 20*time.Millisecond {
+		t.Errorf("waiter should wait for `20ms` before rejection, waited `%s`", d)
+	}
+}
+
+func TestHalfOpenQueueWaiterDeadline(t *testing.T) {
+	cb := NewCircuitBreaker(1, 2, 20*time.Millisecond, 2*time.Second, WithHalfOpenQueue(1, 500*time.Millisecond))
+	makeHalfOpen(t, cb)
+
+	release := make(chan struct{})
+	defer close(release)
+	cb.CallAsync(blockingProbe(release))
+	time.Sleep(10 * time.Millisecond)
+	rejections := cb.Stats().Rejections
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, outcome, err := cb.call(ctx, "", makeService(10, 20, 0))
+	if !errors.Is(err, context.DeadlineExceeded) || outcome != OutcomeCanceled {
+		t.Errorf("waiter should give up at its deadline, got `%s`, `%v`", outcome, err)
+	}
+
+	if d := time.Since(start); d > 200*time.Millisecond {
+		t.Errorf("waiter shouldn't wait past its deadline, waited `%s`", d)
+	}
+	if got := cb.Stats().Rejections; got != rejections {
+		t.Errorf("caller giving up shouldn't count as rejection, got %d", got-rejections)
+	}
+}
+
+func TestHalfOpenQueueWaitOnClock(t *testing.T) {
+	clock := NewFakeClock()
+	cb := NewCircuitBreaker(1, 2, time.Second, 2*time.Second, WithClock(clock), WithHalfOpenQueue(1, time.Minute))
+	callOutcomes(cb, false)
+	clock.Advance(2 * time.Second)
+	// Transition to half open
+	callOutcomes(cb, true)
+
+	release := make(chan struct{})
+	defer close(release)
+	cb.CallAsync(blockingProbe(release))
+	time.Sleep(10 * time.Millisecond)
+
+	waiter := cb.CallAsync(makeService(0, 1, 0))
+	time.Sleep(10 * time.Millisecond)
+	clock.Advance(time.Minute)
+
+	select {
+	case res := <-waiter:
+		if !errors.Is(res.Err, ErrTooManyRequests) {
+			t.Errorf("waiter should be rejected once the clock passes the wait, got `%v`", res.Err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waiter should be driven by the breaker clock")
+	}
+}
+
+func TestHalfOpenQueueOverflow(t *testing.T) {
+	cb := NewCircuitBreaker(1, 2, 20*time.Millisecond, 2*time.Second, WithHalfOpenQueue(1, time.Second))
+	makeHalfOpen(t, cb)
+
+	release := make(chan struct{})
+	defer close(release)
+	cb.CallAsync(blockingProbe(release))
+	time.Sleep(10 * time.Millisecond)
+	// Occupy the only waiter place
+	cb.CallAsync(makeService(10, 20, 0))
+	time.Sleep(10 * time.Millisecond)
+
+	start := time.Now()
+	_, err := cb.Call(makeService(10, 20, 0))
+	if !errors.Is(err, ErrTooManyRequests) {
+		t.Errorf("caller past max waiters should be rejected, got `%v`", err)
+	}
+
+	if d := time.Since(start); d > 100*time.Millisecond {
+		t.Errorf("caller past max waiters should be rejected immediately, waited `%s`", d)
+	}
+}
+
+// callOutcomes calls `cb` once per outcome, failing where the outcome is false
+func callOutcomes(cb *CircuitBreaker, outcomes ...bool) {
+	for _, ok := range outcomes {
+		cb.Call(func() (any, error) {
+			if !ok {
+				return nil, errors.New("service failed")
+			}
+			return "OK", nil
+		})
+	}
+}
+
+func TestHalfOpenSuccessRateCloses(t *testing.T) {
+	cb := NewCircuitBreaker(1, 1, 20*time.Millisecond, 2*time.Second, WithHalfOpenSuccessRate(10, 0.8))
+	makeHalfOpen(t, cb)
+
+	// 8 out of 10 succeed, failures don't re-open the breaker
+	callOutcomes(cb, true, false, true, true, false, true, true, true, true)
+	if cb.State() != StateHalfOpen {
+		t.Fatalf("breaker should stay half open before min calls, got `%s`", cb.State())
+	}
+
+	callOutcomes(cb, true)
+	if cb.State() != StateClosed {
+		t.Errorf("breaker should close once rate is met, got `%s`", cb.State())
+	}
+}
+
+func TestHalfOpenSuccessRateReopens(t *testing.T) {
+	cb := NewCircuitBreaker(1, 1, 20*time.Millisecond, 2*time.Second, WithHalfOpenSuccessRate(10, 0.8))
+	makeHalfOpen(t, cb)
+
+	// Up for a few requests, then failing again
+	callOutcomes(cb, true, true, true, true, false, false)
+	if cb.State() != StateHalfOpen {
+		t.Fatalf("breaker should stay half open while rate is achievable, got `%s`", cb.State())
+	}
+
+	callOutcomes(cb, false)
+	if cb.State() != StateOpen {
+		t.Errorf("breaker should re-open once rate can't be met, got `%s`", cb.State())
+	}
+}
+
+func TestHalfOpenConsecutive(t *testing.T) {
+	cb := NewCircuitBreaker(1, 3, 20*time.Millisecond, 2*time.Second, WithHalfOpenConsecutive(true))
+	makeHalfOpen(t, cb)
+
+	callOutcomes(cb, true, true, false)
+	if cb.State() != StateOpen {
+		t.Errorf("any failed probe should re-open the breaker, got `%s`", cb.State())
+	}
+}
+
+func TestHalfOpenCumulativeCloses(t *testing.T) {
+	cb := NewCircuitBreaker(1, 3, 20*time.Millisecond, 2*time.Second, WithHalfOpenConsecutive(false))
+	makeHalfOpen(t, cb)
+
+	callOutcomes(cb, true, false, true, false)
+	if cb.State() != StateHalfOpen {
+		t.Fatalf("failed probes should be tolerated, got `%s`", cb.State())
+	}
+
+	callOutcomes(cb, true)
+	if cb.State() != StateClosed {
+		t.Errorf("breaker should close once enough probes succeeded, got `%s`", cb.State())
+	}
+}
+
+func TestHalfOpenCumulativeReopens(t *testing.T) {
+	cb := NewCircuitBreaker(1, 2, 20*time.Millisecond, 2*time.Second, WithHalfOpenConsecutive(false))
+	makeHalfOpen(t, cb)
+
+	callOutcomes(cb, false, true, false)
+	if cb.State() != StateHalfOpen {
+		t.Fatalf("breaker should stay half open while failures are tolerated, got `%s`", cb.State())
+	}
+
+	callOutcomes(cb, false)
+	if cb.State() != StateOpen {
+		t.Errorf("breaker should re-open once too many probes failed, got `%s`", cb.State())
+	}
+}
+
+func TestHalfOpenSuccessRateNextCycle(t *testing.T) {
+	cb := NewCircuitBreaker(1, 1, 20*time.Millisecond, 2*time.Second, WithHalfOpenSuccessRate(4, 0.75))
+	makeHalfOpen(t, cb)
+
+	callOutcomes(cb, false, false)
+	if cb.State() != StateOpen {
+		t.Fatalf("breaker should re-open once rate can't be met, got `%s`", cb.State())
+	}
+
+	// Counters start from scratch in the next half open cycle
+	time.Sleep(30 * time.Millisecond)
+	callOutcomes(cb, true)
+	if cb.State() != StateHalfOpen {
+		t.Fatalf("state should move to half open, got `%s`", cb.State())
+	}
+
+	callOutcomes(cb, true, false, true, true)
+	if cb.State() != StateClosed {
+		t.Errorf("breaker should close with 3 out of 4 successes, got `%s`", cb.State())
+	}
+}
+
+func TestMaxConcurrentRejectsExcess(t *testing.T) {
+	cb := NewCircuitBreaker(1, 1, 2*time.Second, 2*time.Second, WithMaxConcurrent(3))
+
+	release := make(chan struct{})
+	var started sync.WaitGroup
+	started.Add(3)
+	slow := func() (any, error) {
+		started.Done()
+		<-release
+		return "OK", nil
+	}
+
+	inFlight := make([]<-chan Result, 3)
+	for i := range inFlight {
+		inFlight[i] = cb.CallAsync(slow)
+	}
+	started.Wait()
+
+	const excess = 7
+	var wg sync.WaitGroup
+	var rejected atomic.Int32
+	for i := 0; i < excess; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := cb.Call(slow); errors.Is(err, ErrBulkheadFull) {
+				rejected.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := rejected.Load(); n != excess {
+		t.Errorf("calls over the limit should be rejected, got `%d` out of `%d`", n, excess)
+	}
+
+	close(release)
+	for _, ch := range inFlight {
+		if res := <-ch; res.Err != nil {
+			t.Errorf("calls within the limit should succeed, got `%v`", res.Err)
+		}
+	}
+
+	if cb.State() != StateClosed {
+		t.Errorf("bulkhead rejections shouldn't open the breaker, got `%s`", cb.State())
+	}
+}
+
+func TestMaxConcurrentReleasesOnTimeout(t *testing.T) {
+	cb := NewCircuitBreaker(10, 1, 2*time.Second, 10*time.Millisecond, WithMaxConcurrent(1))
+
+	// Service slower than the timeout
+	_, err := cb.Call(makeService(50, 100, 0))
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("call should time out, got `%v`", err)
+	}
+
+	if _, err := cb.Call(func() (any, error) { return "OK", nil }); err != nil {
+		t.Errorf("timed out call should free its slot, got `%v`", err)
+	}
+
+	if cb.inFlight != 0 {
+		t.Errorf("no calls should be in flight, got `%d`", cb.inFlight)
+	}
+}
+
+func TestWithClockRecovery(t *testing.T) {
+	clock := NewFakeClock()
+	cb := NewCircuitBreaker(1, 1, time.Minute, time.Second, WithClock(clock))
+
+	callOutcomes(cb, false)
+	clock.Advance(30 * time.Second)
+	callOutcomes(cb, true)
+	if cb.State() != StateOpen {
+		t.Fatalf("breaker should stay open before recovery time, got `%s`", cb.State())
+	}
+
+	clock.Advance(31 * time.Second)
+	callOutcomes(cb, true)
+	if cb.State() != StateHalfOpen {
+		t.Errorf("breaker should move to half open after recovery time, got `%s`", cb.State())
+	}
+}
+
+func TestFailureTTLEvictsStaleFailures(t *testing.T) {
+	clock := NewFakeClock()
+	cb := NewCircuitBreaker(3, 1, time.Minute, time.Second, WithClock(clock), WithFailureTTL(10*time.Second))
+
+	callOutcomes(cb, false, false)
+	// Both failures expire
+	clock.Advance(11 * time.Second)
+
+	callOutcomes(cb, false)
+	if cb.State() != StateClosed {
+		t.Errorf("expired failures shouldn't trip the breaker, got `%s`", cb.State())
+	}
+
+	if s := cb.Snapshot(); s.FailureCount != 1 {
+		t.Errorf("only the recent failure should count, got `%d`", s.FailureCount)
+	}
+
+	callOutcomes(cb, false, false)
+	if cb.State() != StateOpen {
+		t.Errorf("recent failures should trip the breaker, got `%s`", cb.State())
+	}
+}
+
+func TestFailureTTLPartialEviction(t *testing.T) {
+	clock := NewFakeClock()
+	cb := NewCircuitBreaker(3, 1, time.Minute, time.Second, WithClock(clock), WithFailureTTL(10*time.Second))
+
+	callOutcomes(cb, false)
+	clock.Advance(6 * time.Second)
+	callOutcomes(cb, false)
+	clock.Advance(6 * time.Second)
+
+	// First failure expired, second is still recent
+	if s := cb.Snapshot(); s.FailureCount != 1 {
+		t.Errorf("only the recent failure should count, got `%d`", s.FailureCount)
+	}
+
+	callOutcomes(cb, false)
+	if cb.State() != StateClosed {
+		t.Errorf("two recent failures shouldn't trip the breaker, got `%s`", cb.State())
+	}
+}
+
+func TestWithoutFailureTTLKeepsFailures(t *testing.T) {
+	clock := NewFakeClock()
+	cb := NewCircuitBreaker(3, 1, time.Minute, time.Second, WithClock(clock))
+
+	callOutcomes(cb, false, false)
+	clock.Advance(time.Hour)
+	callOutcomes(cb, false)
+
+	if cb.State() != StateOpen {
+		t.Errorf("failures should count regardless of age, got `%s`", cb.State())
+	}
+}
+
+var errRevoked = errors.New("credentials revoked")
+
+func TestImmediateOpenErrors(t *testing.T) {
+	cb := NewCircuitBreaker(100, 1, 2*time.Second, time.Second,
+		WithImmediateOpenErrors(func(err error) bool { return errors.Is(err, errRevoked) }))
+
+	cb.Call(func() (any, error) {
+		return nil, fmt.Errorf("fetch: %w", errRevoked)
+	})
+
+	if cb.State() != StateOpen {
+		t.Errorf("matching error should open the breaker right away, got `%s`", cb.State())
+	}
+}
+
+func TestImmediateOpenErrorsNonMatching(t *testing.T) {
+	cb := NewCircuitBreaker(3, 1, 2*time.Second, time.Second,
+		WithImmediateOpenErrors(func(err error) bool { return errors.Is(err, errRevoked) }))
+
+	callOutcomes(cb, false, false)
+	if cb.State() != StateClosed {
+		t.Fatalf("non matching errors should respect the threshold, got `%s`", cb.State())
+	}
+
+	callOutcomes(cb, false)
+	if cb.State() != StateOpen {
+		t.Errorf("non matching errors should open at the threshold, got `%s`", cb.State())
+	}
+}
+
+// sleepingService succeeds after sleeping for `d`
+func sleepingService(d time.Duration) operation {
+	return func() (any, error) {
+		time.Sleep(d)
+		return "OK", nil
+	}
+}
+
+func TestHalfOpenMaxLatencyFastProbe(t *testing.T) {
+	cb := NewCircuitBreaker(1, 1, 20*time.Millisecond, time.Second, WithHalfOpenMaxLatency(50*time.Millisecond))
+	makeHalfOpen(t, cb)
+
+	cb.Call(sleepingService(time.Millisecond))
+	if cb.State() != StateClosed {
+		t.Errorf("fast probe should close the breaker, got `%s`", cb.State())
+	}
+}
+
+func TestHalfOpenMaxLatencySlowProbe(t *testing.T) {
+	cb := NewCircuitBreaker(1, 1, 20*time.Millisecond, time.Second, WithHalfOpenMaxLatency(20*time.Millisecond))
+	makeHalfOpen(t, cb)
+
+	res, err := cb.Call(sleepingService(50 * time.Millisecond))
+	if err != nil || res != "OK" {
+		t.Errorf("slow probe result should reach the caller, got `%v`, `%v`", res, err)
+	}
+
+	if cb.State() != StateHalfOpen {
+		t.Errorf("slow probe shouldn't close the breaker, got `%s`", cb.State())
+	}
+
+	if cb.successCount != 0 {
+		t.Errorf("slow probe shouldn't count as success, got `%d`", cb.successCount)
+	}
+}
+
+func TestSlowProbeReopen(t *testing.T) {
+	cb := NewCircuitBreaker(1, 1, 20*time.Millisecond, time.Second,
+		WithHalfOpenMaxLatency(20*time.Millisecond), WithSlowProbeReopen(true))
+	makeHalfOpen(t, cb)
+
+	cb.Call(sleepingService(50 * time.Millisecond))
+	if cb.State() != StateOpen {
+		t.Errorf("slow probe should re-open the breaker, got `%s`", cb.State())
+	}
+}
+
+// recoverAfterFailedProbe drives `cb` through open, a failed probe, another
+// open period and a successful probe back to closed state.
+func recoverAfterFailedProbe(t *testing.T, cb *CircuitBreaker, clock *FakeClock) {
+	t.Helper()
+
+	callOutcomes(cb, false, false)
+	clock.Advance(2 * time.Second)
+	// Transition to half open, failed probe re-opens the breaker
+	callOutcomes(cb, true, false)
+	if cb.State() != StateOpen {
+		t.Fatalf("failed probe should re-open the breaker, got `%s`", cb.State())
+	}
+
+	clock.Advance(2 * time.Second)
+	// Transition to half open, successful probe closes the breaker
+	callOutcomes(cb, true, true)
+	if cb.State() != StateClosed {
+		t.Fatalf("successful probe should close the breaker, got `%s`", cb.State())
+	}
+}
+
+func TestProbeFailuresKeptSeparate(t *testing.T) {
+	clock := NewFakeClock()
+	cb := NewCircuitBreaker(2, 1, time.Second, time.Second, WithClock(clock))
+	recoverAfterFailedProbe(t, cb, clock)
+
+	callOutcomes(cb, false)
+	if cb.State() != StateClosed {
+		t.Errorf("failed probe shouldn't count after closing, got `%s`", cb.State())
+	}
+}
+
+func TestProbeFailuresInWindow(t *testing.T) {
+	clock := NewFakeClock()
+	cb := NewCircuitBreaker(2, 1, time.Second, time.Second, WithClock(clock), WithProbeFailuresInWindow(true))
+	recoverAfterFailedProbe(t, cb, clock)
+
+	if s := cb.Snapshot(); s.FailureCount != 1 {
+		t.Errorf("failed probe should be carried over, got `%d` failures", s.FailureCount)
+	}
+
+	callOutcomes(cb, false)
+	if cb.State() != StateOpen {
+		t.Errorf("failed probe should count after closing, got `%s`", cb.State())
+	}
+}
+
+func TestProbeFailuresInWindowExpire(t *testing.T) {
+	clock := NewFakeClock()
+	cb := NewCircuitBreaker(2, 1, time.Second, time.Second,
+		WithClock(clock), WithProbeFailuresInWindow(true), WithFailureTTL(3*time.Second))
+	recoverAfterFailedProbe(t, cb, clock)
+
+	clock.Advance(2 * time.Second)
+	callOutcomes(cb, false)
+	if cb.State() != StateClosed {
+		t.Errorf("expired probe failure shouldn't count, got `%s`", cb.State())
+	}
+}
+
+// halfOpenSample creates a breaker admitting `percent` of `half-open` traffic
+// and moves it into `half-open` state.
+func halfOpenSample(t *testing.T, percent float64, opts ...Option) *CircuitBreaker {
+	t.Helper()
+
+	clock := NewFakeClock()
+	cb := NewCircuitBreaker(1, 1, time.Second, time.Second, append([]Option{
+		WithClock(clock),
+		WithRand(rand.New(rand.NewSource(1))),
+		WithHalfOpenTrafficPercent(percent),
+	}, opts...)...)
+
+	cb.Call(makeService(0, 1, 100))
+	clock.Advance(2 * time.Second)
+	cb.Call(makeService(0, 1, 0))
+
+	if cb.State() != StateHalfOpen {
+		t.Fatalf("state should move to half open, got `%s`", cb.State())
+	}
+
+	return cb
+}
+
+func TestHalfOpenTrafficPercentAdmitFraction(t *testing.T) {
+	// Sample is never large enough to decide recovery
+	cb := halfOpenSample(t, 0.1, WithHalfOpenSuccessRate(10000, 0.5))
+
+	admitted := 0
+	for i := 0; i < 1000; i++ {
+		_, outcome, err := cb.CallDetailed(makeService(0, 1, 0))
+		switch outcome {
+		case OutcomeProbe:
+			admitted++
+		case OutcomeRejectedTooManyRequests:
+			if !errors.Is(err, ErrTooManyRequests) {
+				t.Fatalf("rejection should be `%v`, got `%v`", ErrTooManyRequests, err)
+			}
+		default:
+			t.Fatalf("call should be admitted or rejected, got `%s`", outcome)
+		}
+	}
+
+	if admitted < 70 || admitted > 130 {
+		t.Errorf("about 10%% of calls should be admitted, got %d of 1000", admitted)
+	}
+	if cb.State() != StateHalfOpen {
+		t.Errorf("undecided sample should keep `half-open` state, got `%s`", cb.State())
+	}
+}
+
+func TestHalfOpenTrafficPercentCloses(t *testing.T) {
+	cb := halfOpenSample(t, 0.5, WithHalfOpenSuccessRate(10, 0.8))
+
+	// Only the third admitted call fails
+	calls := 0
+	service := func() (any, error) {
+		calls++
+		if calls == 3 {
+			return nil, errors.New("service failed")
+		}
+		return "OK", nil
+	}
+	for i := 0; i < 100 && cb.State() == StateHalfOpen; i++ {
+		cb.Call(service)
+	}
+
+	if cb.State() != StateClosed {
+		t.Errorf("sample with 90%% success rate should close the breaker, got `%s`", cb.State())
+	}
+}
+
+func TestHalfOpenTrafficPercentReopens(t *testing.T) {
+	cb := halfOpenSample(t, 0.5, WithHalfOpenSuccessRate(10, 0.8))
+
+	// Every other admitted call fails
+	calls := 0
+	service := func() (any, error) {
+		calls++
+		if calls%2 == 0 {
+			return nil, errors.New("service failed")
+		}
+		return "OK", nil
+	}
+	for i := 0; i < 100 && cb.State() == StateHalfOpen; i++ {
+		cb.Call(service)
+	}
+
+	if cb.State() != StateOpen {
+		t.Errorf("sample with 50%% success rate should re-open the breaker, got `%s`", cb.State())
+	}
+	if calls > 10 {
+		t.Errorf("breaker should re-open once the rate can't be met, got %d admitted calls", calls)
+	}
+}
+
+func TestRecoveryJitterBounds(t *testing.T) {
+	clock := NewFakeClock()
+	recovery := time.Second
+	cb := NewCircuitBreaker(1, 1, recovery, time.Second,
+		WithClock(clock),
+		WithRand(rand.New(rand.NewSource(1))),
+		WithRecoveryJitter(0.2),
+	)
+
+	lowest, highest := time.Duration(math.MaxInt64), time.Duration(0)
+	for i := 0; i < 200; i++ {
+		cb.Call(makeService(0, 1, 100))
+
+		// Clock stands still, time left is the whole recovery time of the cycle
+		d := cb.RetryAfter()
+		if d < 800*time.Millisecond || d > 1200*time.Millisecond {
+			t.Fatalf("recovery time should stay within 20%% of `%s`, got `%s`", recovery, d)
+		}
+		lowest, highest = min(lowest, d), max(highest, d)
+
+		clock.Advance(d - time.Millisecond)
+		if _, err := cb.Call(makeService(0, 1, 0)); !errors.Is(err, ErrOpenState) {
+			t.Fatalf("breaker should stay open until jittered recovery time `%s`, got `%v`", d, err)
+		}
+
+		clock.Advance(2 * time.Millisecond)
+		cb.Call(makeService(0, 1, 0))
+		cb.Call(makeService(0, 1, 0))
+		if cb.State() != StateClosed {
+			t.Fatalf("breaker should recover after jittered recovery time `%s`, got `%s`", d, cb.State())
+		}
+	}
+
+	if lowest > 850*time.Millisecond || highest < 1150*time.Millisecond {
+		t.Errorf("recovery time should spread over the jitter range, got [%s, %s]", lowest, highest)
+	}
+}
+
+func TestRecoveryJitterDisabled(t *testing.T) {
+	clock := NewFakeClock()
+	cb := NewCircuitBreaker(1, 1, time.Second, time.Second, WithClock(clock))
+
+	cb.Call(makeService(0, 1, 100))
+	if d := cb.RetryAfter(); d != time.Second {
+		t.Errorf("recovery time without jitter should be `1s`, got `%s`", d)
+	}
+}
+
+func TestTimeoutThreshold(t *testing.T) {
+	cb := NewCircuitBreaker(5, 1, time.Minute, 10*time.Millisecond, WithTimeoutThreshold(2))
+	slow := sleepingService(50 * time.Millisecond)
+
+	cb.Call(slow)
+	if cb.State() != StateClosed {
+		t.Fatalf("single timeout shouldn't open the breaker, got `%s`", cb.State())
+	}
+
+	cb.Call(slow)
+	if cb.State() != StateOpen {
+		t.Errorf("two timeouts should open the breaker before the failure threshold, got `%s`", cb.State())
+	}
+}
+
+func TestTimeoutThresholdConsecutive(t *testing.T) {
+	cb := NewCircuitBreaker(5, 1, time.Minute, 10*time.Millisecond, WithTimeoutThreshold(2))
+	slow := sleepingService(50 * time.Millisecond)
+
+	// Any other outcome breaks the run of timeouts
+	cb.Call(slow)
+	cb.Call(makeService(0, 1, 100))
+	cb.Call(slow)
+	cb.Call(makeService(0, 1, 100))
+	if cb.State() != StateClosed {
+		t.Fatalf("interleaved timeouts shouldn't open the breaker, got `%s`", cb.State())
+	}
+
+	// Timeouts still count as failures
+	cb.Call(slow)
+	if cb.State() != StateOpen {
+		t.Errorf("failure threshold should still count timeouts, got `%s`", cb.State())
+	}
+}
+
+func TestTimeoutThresholdErrors(t *testing.T) {
+	cb := NewCircuitBreaker(3, 1, time.Minute, time.Second, WithTimeoutThreshold(1))
+
+	cb.Call(makeService(0, 1, 100))
+	cb.Call(makeService(0, 1, 100))
+	if cb.State() != StateClosed {
+		t.Errorf("errors shouldn't count towards the timeout threshold, got `%s`", cb.State())
+	}
+}
+
+func TestResultOnErrorPassThrough(t *testing.T) {
+	for _, cb := range []*CircuitBreaker{
+		NewCircuitBreaker(5, 1, time.Minute, time.Second),
+		NewCircuitBreaker(5, 1, time.Minute, time.Second, WithResultOnError(true)),
+	} {
+		res, err := cb.Call(unavailableService)
+		if err == nil || res != "retry in 30s" {
+			t.Errorf("both result and error should pass through, got `%v`, `%v`", res, err)
+		}
+	}
+}
+
+func TestResultOnErrorDropped(t *testing.T) {
+	clock := NewFakeClock()
+	cb := NewCircuitBreaker(1, 1, time.Second, time.Second, WithClock(clock), WithResultOnError(false))
+
+	res, err := cb.Call(unavailableService)
+	if err == nil || res != nil {
+		t.Errorf("error should win in closed state, got `%v`, `%v`", res, err)
+	}
+
+	clock.Advance(2 * time.Second)
+	// Transition to half open state
+	cb.Call(unavailableService)
+
+	res, err = cb.Call(unavailableService)
+	if err == nil || res != nil {
+		t.Errorf("error should win in half open state, got `%v`, `%v`", res, err)
+	}
+
+	res, err = cb.Call(makeService(0, 1, 0))
+	if !errors.Is(err, ErrOpenState) || res != nil {
+		t.Errorf("rejected call should have no result, got `%v`, `%v`", res, err)
+	}
+}
+
+func TestResultOnErrorSuccess(t *testing.T) {
+	cb := NewCircuitBreaker(5, 1, time.Minute, time.Second, WithResultOnError(false))
+
+	if res, err := cb.Call(makeService(0, 1, 0)); err != nil || res != "OK" {
+		t.Errorf("successful result should be returned, got `%v`, `%v`", res, err)
+	}
+}
@@ -0,0 +1,187 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// CallOutcome tells what the circuit breaker did with a single call.
+type CallOutcome int
+
+const (
+	// Operation was executed
+	OutcomeExecuted CallOutcome = iota
+	// Operation was executed as the `half-open` probe
+	OutcomeProbe
+	// Operation didn't complete within the timeout
+	OutcomeTimedOut
+	// Operation was abandoned because the caller's context is done
+	OutcomeCanceled
+	// Call was rejected by `open` state
+	OutcomeRejectedOpen
+	// Call was rejected because the `half-open` probe is already in flight
+	OutcomeRejectedTooManyRequests
+	// Call was rejected because too many calls are in flight
+	OutcomeBulkheadFull
+	// Call was rejected because the breaker is shut down
+	OutcomeRejectedClosed
+	// Call moved the breaker from `open` to `half-open` state without
+	// executing the operation
+	OutcomeTransitioned
+	// Call was rejected by the rate limit
+	OutcomeRateLimited
+	// Call was refused because the operation is `nil`
+	OutcomeNilOperation
+	// Call was rejected by `recovering` state
+	OutcomeThrottled
+	// Call was admitted but the executor couldn't run it, being saturated or
+	// closed
+	OutcomeSaturated
+)
+
+func (o CallOutcome) String() string {
+	switch o {
+	case OutcomeExecuted:
+		return "executed"
+	case OutcomeProbe:
+		return "probe"
+	case OutcomeTimedOut:
+		return "timed-out"
+	case OutcomeCanceled:
+		return "canceled"
+	case OutcomeRejectedOpen:
+		return "rejected-open"
+	case OutcomeRejectedTooManyRequests:
+		return "rejected-too-many-requests"
+	case OutcomeBulkheadFull:
+		return "bulkhead-full"
+	case OutcomeRejectedClosed:
+		return "rejected-closed"
+	case OutcomeTransitioned:
+		return "transitioned"
+	case OutcomeRateLimited:
+		return "rate-limited"
+	case OutcomeNilOperation:
+		return "nil-operation"
+	case OutcomeThrottled:
+		return "throttled"
+	case OutcomeSaturated:
+		return "saturated"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(o))
+	}
+}
+
+// rejectionOutcome maps the error of a rejected call to its outcome.
+func rejectionOutcome(err error) CallOutcome {
+	switch {
+	case errors.Is(err, ErrOpenState):
+		return OutcomeRejectedOpen
+	case errors.Is(err, ErrTooManyRequests):
+		return OutcomeRejectedTooManyRequests
+	case errors.Is(err, ErrBulkheadFull):
+		return OutcomeBulkheadFull
+	case errors.Is(err, ErrRateLimited):
+		return OutcomeRateLimited
+	case errors.Is(err, ErrThrottled):
+		return OutcomeThrottled
+	default:
+		return OutcomeRejectedClosed
+	}
+}
+
+// executionOutcome maps the result of an executed operation to its outcome.
+func executionOutcome(ctx context.Context, t ticket, err error) CallOutcome {
+	switch {
+	case isCanceled(ctx, err):
+		return OutcomeCanceled
+	case notStarted(err):
+		return OutcomeSaturated
+	case errors.Is(err, ErrTimeout):
+		return OutcomeTimedOut
+	case t.state == StateHalfOpen && !t.bypass:
+		return OutcomeProbe
+	default:
+		return OutcomeExecuted
+	}
+}
+
+// RejectReason tells why the circuit breaker shed a call.
+type RejectReason int
+
+const (
+	// Call was rejected by `open` state
+	RejectOpen RejectReason = iota
+	// Call was rejected because too many calls are in flight
+	RejectBulkheadFull
+	// Call was rejected because the `half-open` probe is already in flight
+	RejectTooManyProbes
+	// Call was rejected by the rate limit
+	RejectRateLimited
+	// Call was rejected by `recovering` state
+	RejectThrottled
+)
+
+func (r RejectReason) String() string {
+	switch r {
+	case RejectOpen:
+		return "open"
+	case RejectBulkheadFull:
+		return "bulkhead-full"
+	case RejectTooManyProbes:
+		return "too-many-probes"
+	case RejectRateLimited:
+		return "rate-limited"
+	case RejectThrottled:
+		return "throttled"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(r))
+	}
+}
+
+// WithOnReject invokes `fn` for every call shed by the breaker. It runs
+// synchronously on the caller's goroutine without holding the lock.
+func WithOnReject(fn func(name string, reason RejectReason)) Option {
+	return func(cb *CircuitBreaker) {
+		cb.onReject = fn
+	}
+}
+
+// notifyReject reports a call rejected with `outcome` to the reject hook.
+func (cb *CircuitBreaker) notifyReject(outcome CallOutcome) {
+	if cb.onReject == nil {
+		return
+	}
+
+	switch outcome {
+	case OutcomeRejectedOpen:
+		cb.onReject(cb.name, RejectOpen)
+	case OutcomeBulkheadFull:
+		cb.onReject(cb.name, RejectBulkheadFull)
+	case OutcomeRejectedTooManyRequests:
+		cb.onReject(cb.name, RejectTooManyProbes)
+	case OutcomeRateLimited:
+		cb.onReject(cb.name, RejectRateLimited)
+	case OutcomeThrottled:
+		cb.onReject(cb.name, RejectThrottled)
+	}
+}
+
+// WithObserver invokes `fn` after every call with the context of the call,
+// the time the operation took, the error returned to the caller and the
+// outcome. Calls which didn't execute the operation report zero duration. It
+// runs synchronously on the caller's goroutine without holding the lock.
+func WithObserver(fn func(ctx context.Context, name string, duration time.Duration, err error, outcome CallOutcome)) Option {
+	return func(cb *CircuitBreaker) {
+		cb.observer = fn
+	}
+}
+
+// observe reports a completed call to the observer.
+func (cb *CircuitBreaker) observe(ctx context.Context, duration time.Duration, err error, outcome CallOutcome) {
+	if cb.observer != nil {
+		cb.observer(ctx, cb.name, duration, err, outcome)
+	}
+}
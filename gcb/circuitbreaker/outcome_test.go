@@ -0,0 +1,247 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCallDetailedExecuted(t *testing.T) {
+	cb := NewCircuitBreaker(2, 1, 2*time.Second, time.Second)
+
+	res, outcome, err := cb.CallDetailed(makeService(10, 20, 0))
+	if outcome != OutcomeExecuted || err != nil || res != "OK" {
+		t.Errorf("successful call should be executed, got `%s`, `%v`", outcome, err)
+	}
+
+	_, outcome, err = cb.CallDetailed(makeService(10, 20, 100))
+	if outcome != OutcomeExecuted || err == nil {
+		t.Errorf("failed call should be executed, got `%s`, `%v`", outcome, err)
+	}
+}
+
+func TestCallDetailedTimedOut(t *testing.T) {
+	cb := NewCircuitBreaker(2, 1, 2*time.Second, 10*time.Millisecond)
+
+	_, outcome, err := cb.CallDetailed(makeService(50, 100, 0))
+	if outcome != OutcomeTimedOut || !errors.Is(err, ErrTimeout) {
+		t.Errorf("slow call should time out, got `%s`, `%v`", outcome, err)
+	}
+}
+
+func TestCallDetailedCanceled(t *testing.T) {
+	cb := NewCircuitBreaker(2, 1, 2*time.Second, time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, outcome, err := cb.call(ctx, "", makeService(50, 100, 0))
+	if outcome != OutcomeCanceled || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("call should be canceled by the caller, got `%s`, `%v`", outcome, err)
+	}
+}
+
+func TestCallDetailedOpenAndProbe(t *testing.T) {
+	cb := NewCircuitBreaker(1, 1, 20*time.Millisecond, time.Second)
+	callOutcomes(cb, false)
+
+	_, outcome, err := cb.CallDetailed(makeService(10, 20, 0))
+	if outcome != OutcomeRejectedOpen || !errors.Is(err, ErrOpenState) {
+		t.Errorf("call should be rejected by open state, got `%s`, `%v`", outcome, err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	_, outcome, err = cb.CallDetailed(makeService(10, 20, 0))
+	if outcome != OutcomeTransitioned || err != nil {
+		t.Errorf("call should transition to half open, got `%s`, `%v`", outcome, err)
+	}
+
+	_, outcome, err = cb.CallDetailed(makeService(10, 20, 0))
+	if outcome != OutcomeProbe || err != nil {
+		t.Errorf("call should be the half open probe, got `%s`, `%v`", outcome, err)
+	}
+}
+
+func TestCallDetailedTooManyRequests(t *testing.T) {
+	cb := NewCircuitBreaker(1, 1, 20*time.Millisecond, time.Second)
+	makeHalfOpen(t, cb)
+
+	release := make(chan struct{})
+	defer close(release)
+	cb.CallAsync(blockingProbe(release))
+	time.Sleep(10 * time.Millisecond)
+
+	_, outcome, err := cb.CallDetailed(makeService(10, 20, 0))
+	if outcome != OutcomeRejectedTooManyRequests || !errors.Is(err, ErrTooManyRequests) {
+		t.Errorf("call should be rejected while probe is in flight, got `%s`, `%v`", outcome, err)
+	}
+}
+
+func TestCallDetailedBulkheadFull(t *testing.T) {
+	cb := NewCircuitBreaker(1, 1, 2*time.Second, time.Second, WithMaxConcurrent(1))
+
+	release := make(chan struct{})
+	defer close(release)
+	cb.CallAsync(blockingProbe(release))
+	time.Sleep(10 * time.Millisecond)
+
+	_, outcome, err := cb.CallDetailed(makeService(10, 20, 0))
+	if outcome != OutcomeBulkheadFull || !errors.Is(err, ErrBulkheadFull) {
+		t.Errorf("call should be rejected by bulkhead, got `%s`, `%v`", outcome, err)
+	}
+}
+
+func TestCallDetailedClosed(t *testing.T) {
+	cb := NewCircuitBreaker(1, 1, 2*time.Second, time.Second)
+	cb.Close()
+
+	_, outcome, err := cb.CallDetailed(makeService(10, 20, 0))
+	if outcome != OutcomeRejectedClosed || !errors.Is(err, ErrClosed) {
+		t.Errorf("call should be rejected by shut down breaker, got `%s`, `%v`", outcome, err)
+	}
+}
+
+// rejectRecorder collects reasons reported to the reject hook
+type rejectRecorder struct {
+	mu      sync.Mutex
+	reasons []RejectReason
+}
+
+func (r *rejectRecorder) record(name string, reason RejectReason) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.reasons = append(r.reasons, reason)
+}
+
+func (r *rejectRecorder) list() []RejectReason {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return slices.Clone(r.reasons)
+}
+
+func TestOnRejectOpen(t *testing.T) {
+	rec := &rejectRecorder{}
+	cb := NewCircuitBreaker(1, 1, time.Minute, time.Second, WithOnReject(rec.record))
+
+	callOutcomes(cb, false)
+	if got := rec.list(); len(got) != 0 {
+		t.Errorf("executed calls shouldn't be reported, got `%v`", got)
+	}
+
+	callOutcomes(cb, true, true)
+	if got := rec.list(); !slices.Equal(got, []RejectReason{RejectOpen, RejectOpen}) {
+		t.Errorf("open rejections should be reported, got `%v`", got)
+	}
+}
+
+func TestOnRejectBulkheadFull(t *testing.T) {
+	rec := &rejectRecorder{}
+	cb := NewCircuitBreaker(1, 1, time.Minute, time.Second, WithOnReject(rec.record), WithMaxConcurrent(1))
+
+	release := make(chan struct{})
+	defer close(release)
+	cb.CallAsync(blockingProbe(release))
+	time.Sleep(10 * time.Millisecond)
+
+	callOutcomes(cb, true)
+	if got := rec.list(); !slices.Equal(got, []RejectReason{RejectBulkheadFull}) {
+		t.Errorf("bulkhead rejection should be reported, got `%v`", got)
+	}
+}
+
+func TestOnRejectTooManyProbes(t *testing.T) {
+	rec := &rejectRecorder{}
+	cb := NewCircuitBreaker(1, 1, 20*time.Millisecond, time.Second, WithOnReject(rec.record))
+	makeHalfOpen(t, cb)
+
+	release := make(chan struct{})
+	defer close(release)
+	cb.CallAsync(blockingProbe(release))
+	time.Sleep(10 * time.Millisecond)
+
+	callOutcomes(cb, true)
+	got := rec.list()
+	if len(got) == 0 || got[len(got)-1] != RejectTooManyProbes {
+		t.Errorf("probe rejection should be reported, got `%v`", got)
+	}
+}
+
+func TestOnRejectName(t *testing.T) {
+	var got string
+	cb := NewCircuitBreaker(1, 1, time.Minute, time.Second, WithName("payments"),
+		WithOnReject(func(name string, reason RejectReason) { got = name }))
+
+	callOutcomes(cb, false, true)
+	if got != "payments" {
+		t.Errorf("reject hook should receive breaker name, got `%s`", got)
+	}
+}
+
+// observation is a call reported to the observer
+type observation struct {
+	name     string
+	duration time.Duration
+	err      error
+	outcome  CallOutcome
+}
+
+func TestObserver(t *testing.T) {
+	var got []observation
+	var cb *CircuitBreaker
+	cb = NewCircuitBreaker(2, 1, time.Minute, 50*time.Millisecond,
+		WithName("payments"),
+		WithObserver(func(_ context.Context, name string, d time.Duration, err error, outcome CallOutcome) {
+			// Taking the lock deadlocks if the observer runs under it
+			cb.Snapshot()
+			got = append(got, observation{name, d, err, outcome})
+		}),
+	)
+
+	cb.Call(sleepingService(20 * time.Millisecond))
+	cb.Call(sleepingService(100 * time.Millisecond))
+	cb.Call(makeService(0, 1, 100))
+	cb.Call(makeService(0, 1, 0))
+	cb.Call(nil)
+
+	if len(got) != 5 {
+		t.Fatalf("observer should be invoked for every call, got `%+v`", got)
+	}
+
+	for _, o := range got {
+		if o.name != "payments" {
+			t.Errorf("observer should be given the breaker name, got `%s`", o.name)
+		}
+	}
+
+	executed := got[0]
+	if executed.outcome != OutcomeExecuted || executed.err != nil ||
+		executed.duration < 20*time.Millisecond || executed.duration > 50*time.Millisecond {
+		t.Errorf("executed call should be observed with its duration, got `%+v`", executed)
+	}
+
+	timedOut := got[1]
+	if timedOut.outcome != OutcomeTimedOut || !errors.Is(timedOut.err, ErrTimeout) ||
+		timedOut.duration < 50*time.Millisecond || timedOut.duration > 100*time.Millisecond {
+		t.Errorf("timed out call should be observed with the timeout duration, got `%+v`", timedOut)
+	}
+
+	failed := got[2]
+	if failed.outcome != OutcomeExecuted || failed.err == nil {
+		t.Errorf("failed call should be observed with its error, got `%+v`", failed)
+	}
+
+	rejected := got[3]
+	if rejected.outcome != OutcomeRejectedOpen || !errors.Is(rejected.err, ErrOpenState) || rejected.duration != 0 {
+		t.Errorf("rejected call should be observed with zero duration, got `%+v`", rejected)
+	}
+
+	refused := got[4]
+	if refused.outcome != OutcomeNilOperation || !errors.Is(refused.err, ErrNilOperation) || refused.duration != 0 {
+		t.Errorf("nil operation should be observed with zero duration, got `%+v`", refused)
+	}
+}
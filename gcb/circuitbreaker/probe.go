@@ -0,0 +1,74 @@
+package circuitbreaker
+
+import (
+	"context"
+	"time"
+)
+
+// defaultProbeInterval is used for probe functions without
+// `WithProbeInterval`
+const defaultProbeInterval = 100 * time.Millisecond
+
+// WithProbeFunc lets `probe` decide recovery instead of user requests, e.g.
+// by calling a lightweight health endpoint of the dependency. While the
+// breaker is in `half-open` state `probe` runs repeatedly, subject to the
+// timeout and spaced by the probe interval, and its outcomes are accounted
+// as probes. User requests are rejected with `ErrTooManyRequests` until the
+// breaker closes.
+func WithProbeFunc(probe func() error) Option {
+	return func(cb *CircuitBreaker) {
+		cb.probeFunc = probe
+	}
+}
+
+// WithProbeInterval sets the time interval on the clock between runs of the
+// probe function set with `WithProbeFunc`. The first probe of each
+// `half-open` state runs right away. Defaults to 100ms.
+func WithProbeInterval(interval time.Duration) Option {
+	return func(cb *CircuitBreaker) {
+		cb.probeInterval = interval
+	}
+}
+
+// scheduleProbeFunc schedules a run of the probe function in the
+// `half-open` state of `generation` on the clock once `delay` passes.
+func (cb *CircuitBreaker) scheduleProbeFunc(generation uint64, delay time.Duration) {
+	cb.wg.Add(1)
+	cb.probeTimer = cb.clock.AfterFunc(delay, func() {
+		defer cb.wg.Done()
+		cb.runProbeFunc(generation)
+	})
+}
+
+// runProbeFunc runs the probe function while the breaker stays in the
+// `half-open` state of `generation`, scheduling the next run unless the
+// probe decided recovery.
+func (cb *CircuitBreaker) runProbeFunc(generation uint64) {
+	cb.mu.Lock()
+	if cb.generation != generation || cb.shutdown {
+		cb.unlock()
+		return
+	}
+	cb.probeTimer = nil
+	timeout := cb.effectiveTimeout()
+	cb.unlock()
+
+	probe := func() (any, error) {
+		return nil, cb.probeFunc()
+	}
+
+	// Probe runs without holding the lock
+	_, elapsed, err := cb.runWithTimeout(context.Background(), timeout, probe)
+
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	if cb.generation != generation {
+		return
+	}
+
+	cb.processHalfOpenState(context.Background(), nil, elapsed, err)
+	if cb.generation == generation && !cb.shutdown {
+		cb.scheduleProbeFunc(generation, cb.probeInterval)
+	}
+}
@@ -0,0 +1,149 @@
+package circuitbreaker
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestProbeFuncGovernsRecovery(t *testing.T) {
+	clock := NewFakeClock()
+
+	var probes atomic.Int32
+	probe := func() error {
+		probes.Add(1)
+		return nil
+	}
+
+	cb := NewCircuitBreaker(1, 3, time.Second, time.Second,
+		WithClock(clock),
+		WithAutoHalfOpen(true),
+		WithProbeFunc(probe),
+	)
+	defer cb.Close()
+
+	cb.Call(makeService(0, 1, 100))
+	clock.Advance(2 * time.Second)
+
+	if cb.State() != StateHalfOpen {
+		t.Fatalf("breaker should move to half open, got `%s`", cb.State())
+	}
+
+	// User requests stay off the dependency while probing
+	executed := false
+	_, err := cb.Call(func() (any, error) {
+		executed = true
+		return "OK", nil
+	})
+	if !errors.Is(err, ErrTooManyRequests) || executed {
+		t.Errorf("user request should be rejected while probing, got `%v`", err)
+	}
+
+	clock.Advance(defaultProbeInterval)
+	clock.Advance(defaultProbeInterval)
+	if cb.State() != StateClosed {
+		t.Fatalf("successful probes should close the breaker, got `%s`", cb.State())
+	}
+	if n := probes.Load(); n != 3 {
+		t.Errorf("probe should run until the half-open threshold, got %d runs", n)
+	}
+
+	if _, err := cb.Call(makeService(0, 1, 0)); err != nil {
+		t.Errorf("closed breaker should admit user requests, got `%v`", err)
+	}
+}
+
+func TestProbeFuncInterval(t *testing.T) {
+	clock := NewFakeClock()
+
+	var probes atomic.Int32
+	cb := NewCircuitBreaker(1, 10, time.Second, time.Second,
+		WithClock(clock),
+		WithAutoHalfOpen(true),
+		WithProbeFunc(func() error {
+			probes.Add(1)
+			return nil
+		}),
+		WithProbeInterval(time.Second),
+	)
+	defer cb.Close()
+
+	cb.Call(makeService(0, 1, 100))
+	clock.Advance(2 * time.Second)
+	if n := probes.Load(); n != 1 {
+		t.Fatalf("first probe should run on entering half open, got %d runs", n)
+	}
+
+	clock.Advance(500 * time.Millisecond)
+	if n := probes.Load(); n != 1 {
+		t.Errorf("probe shouldn't run before the interval passes, got %d runs", n)
+	}
+
+	for range 4 {
+		clock.Advance(500 * time.Millisecond)
+	}
+	if n := probes.Load(); n != 3 {
+		t.Errorf("probe should run once per interval, got %d runs", n)
+	}
+}
+
+func TestProbeFuncFailureReopens(t *testing.T) {
+	clock := NewFakeClock()
+
+	var probes atomic.Int32
+	cb := NewCircuitBreaker(1, 3, time.Second, time.Second,
+		WithClock(clock),
+		WithProbeFunc(func() error {
+			probes.Add(1)
+			return errors.New("still down")
+		}),
+	)
+	defer cb.Close()
+
+	cb.Call(makeService(0, 1, 100))
+	clock.Advance(2 * time.Second)
+	// Transition to half open state
+	cb.Call(makeService(0, 1, 0))
+
+	clock.Advance(0)
+	if cb.State() != StateOpen {
+		t.Fatalf("failed probe should re-open the breaker, got `%s`", cb.State())
+	}
+
+	clock.Advance(defaultProbeInterval)
+	if n := probes.Load(); n != 1 {
+		t.Errorf("probing should stop once the breaker re-opens, got %d runs", n)
+	}
+}
+
+func TestProbeFuncStopsOnClose(t *testing.T) {
+	clock := NewFakeClock()
+
+	var probes atomic.Int32
+	cb := NewCircuitBreaker(1, 1, time.Second, time.Second,
+		WithClock(clock),
+		WithProbeFunc(func() error {
+			probes.Add(1)
+			time.Sleep(time.Millisecond)
+			return nil
+		}),
+		// Probes are never fast enough to count
+		WithHalfOpenMaxLatency(time.Nanosecond),
+	)
+
+	cb.Call(makeService(0, 1, 100))
+	clock.Advance(2 * time.Second)
+	cb.Call(makeService(0, 1, 0))
+	clock.Advance(0)
+	cb.Close()
+
+	if n := clock.Pending(); n != 0 {
+		t.Errorf("close should cancel the scheduled probe, got `%d` pending", n)
+	}
+
+	clock.Advance(time.Second)
+	if n := probes.Load(); n != 1 {
+		t.Errorf("probing should stop after close, got %d runs", n)
+	}
+}
@@ -0,0 +1,57 @@
+package circuitbreaker
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrRateLimited is returned for calls exceeding the rate limit.
+var ErrRateLimited = errors.New("rate limited; too many requests")
+
+// WithRateLimit caps the rate of calls at `rps` per second regardless of the
+// state, allowing bursts of up to `burst` calls. Calls over the limit are
+// rejected with `ErrRateLimited` before the state is consulted. The limit is
+// a token bucket refilled on the breaker clock, starting full.
+func WithRateLimit(rps float64, burst int) Option {
+	return func(cb *CircuitBreaker) {
+		cb.rateLimit = rps
+		cb.rateBurst = burst
+	}
+}
+
+// limitRate takes a token for the call unless `dry`, rejecting it if none is
+// left. Must be called with the lock held.
+func (cb *CircuitBreaker) limitRate(dry bool) error {
+	if cb.rateLimit <= 0 || cb.shutdown || cb.disabled {
+		return nil
+	}
+
+	now := cb.clock.Now()
+	tokens := cb.tokensAt(now)
+	if !dry {
+		cb.tokens = tokens
+		cb.tokensRefilled = now
+	}
+
+	if tokens < 1 {
+		err := cb.reject(ErrRateLimited)
+		// Time until the next token is available
+		err.RetryAfter = time.Duration((1 - tokens) / cb.rateLimit * float64(time.Second))
+		return err
+	}
+
+	if !dry {
+		cb.tokens--
+	}
+	return nil
+}
+
+// tokensAt returns tokens in the bucket refilled up to `now`.
+func (cb *CircuitBreaker) tokensAt(now time.Time) float64 {
+	if cb.tokensRefilled.IsZero() {
+		return float64(cb.rateBurst)
+	}
+
+	refill := now.Sub(cb.tokensRefilled).Seconds() * cb.rateLimit
+	return min(float64(cb.rateBurst), cb.tokens+refill)
+}
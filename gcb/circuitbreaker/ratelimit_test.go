@@ -0,0 +1,100 @@
+package circuitbreaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// admitted makes `n` calls through `cb` and returns how many weren't rate
+// limited.
+func admitted(cb *CircuitBreaker, n int) int {
+	count := 0
+	for i := 0; i < n; i++ {
+		if _, err := cb.Call(makeService(0, 1, 0)); !errors.Is(err, ErrRateLimited) {
+			count++
+		}
+	}
+
+	return count
+}
+
+func TestRateLimitBurst(t *testing.T) {
+	clock := NewFakeClock()
+	cb := NewCircuitBreaker(1, 1, time.Minute, time.Second, WithClock(clock), WithRateLimit(10, 5))
+
+	if n := admitted(cb, 8); n != 5 {
+		t.Errorf("burst should be admitted, got %d calls", n)
+	}
+
+	_, outcome, err := cb.CallDetailed(makeService(0, 1, 0))
+	var breakerErr *BreakerError
+	if outcome != OutcomeRateLimited || !errors.As(err, &breakerErr) || breakerErr.RetryAfter != 100*time.Millisecond {
+		t.Errorf("call over the limit should wait for the next token, got `%s`, `%v`", outcome, err)
+	}
+	if cb.State() != StateClosed {
+		t.Errorf("rate limited calls shouldn't count as failures, got `%s`", cb.State())
+	}
+}
+
+func TestRateLimitSustained(t *testing.T) {
+	clock := NewFakeClock()
+	cb := NewCircuitBreaker(1, 1, time.Minute, time.Second, WithClock(clock), WithRateLimit(10, 5))
+	admitted(cb, 5)
+
+	// Tokens refill at 10 per second
+	total := 0
+	for i := 0; i < 20; i++ {
+		clock.Advance(50 * time.Millisecond)
+		total += admitted(cb, 3)
+	}
+	if total != 10 {
+		t.Errorf("sustained rate should be admitted, got %d calls in a second", total)
+	}
+
+	// Bucket doesn't fill past the burst
+	clock.Advance(time.Hour)
+	if n := admitted(cb, 10); n != 5 {
+		t.Errorf("idle period should refill up to the burst, got %d calls", n)
+	}
+}
+
+func TestRateLimitBeforeState(t *testing.T) {
+	clock := NewFakeClock()
+	var reasons []RejectReason
+	cb := NewCircuitBreaker(1, 1, time.Minute, time.Second, WithClock(clock), WithRateLimit(1, 1),
+		WithOnReject(func(_ string, reason RejectReason) { reasons = append(reasons, reason) }))
+
+	cb.Call(makeService(0, 1, 100))
+	if _, err := cb.Call(makeService(0, 1, 0)); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("rate limit should be checked before the state, got `%v`", err)
+	}
+
+	clock.Advance(time.Second)
+	if _, err := cb.Call(makeService(0, 1, 0)); !errors.Is(err, ErrOpenState) {
+		t.Errorf("call within the limit should reach the state, got `%v`", err)
+	}
+
+	if len(reasons) != 2 || reasons[0] != RejectRateLimited || reasons[1] != RejectOpen {
+		t.Errorf("rejections should be reported with their reason, got `%v`", reasons)
+	}
+}
+
+func TestRateLimitAllowRequest(t *testing.T) {
+	clock := NewFakeClock()
+	cb := NewCircuitBreaker(1, 1, time.Minute, time.Second, WithClock(clock), WithRateLimit(1, 1))
+
+	if !cb.AllowRequest() || !cb.AllowRequest() {
+		t.Fatal("breaker with a token left should allow requests, the check doesn't take it")
+	}
+
+	cb.Call(makeService(0, 1, 0))
+	if cb.AllowRequest() {
+		t.Error("breaker out of tokens shouldn't allow requests")
+	}
+
+	clock.Advance(time.Second)
+	if !cb.AllowRequest() {
+		t.Error("breaker should allow requests once a token is refilled")
+	}
+}
@@ -0,0 +1,53 @@
+package circuitbreaker
+
+import (
+	"context"
+	"log/slog"
+)
+
+// WithRecoveringState inserts `recovering` state between `half-open` and
+// `closed` states for dependencies which need time to warm up. Once probes
+// succeed the breaker moves to `recovering` state, admitting `fraction` of
+// calls and rejecting the rest with `ErrThrottled`. It closes after
+// `successes` admitted calls succeeded, while any failure re-opens it. Zero
+// `fraction` skips the state, closing right after `half-open` state.
+func WithRecoveringState(fraction float64, successes int) Option {
+	return func(cb *CircuitBreaker) {
+		cb.recoveringFraction = fraction
+		cb.recoveringSuccesses = successes
+	}
+}
+
+// toRecovering transitions the circuit breaker from `half-open` into
+// `recovering` state.
+func (cb *CircuitBreaker) toRecovering() {
+	cb.setState(StateRecovering)
+	cb.successCount = 0
+	cb.halfOpenAttempts = 0
+}
+
+// processRecoveringState accounts for the outcome of a call admitted in
+// `recovering` state.
+func (cb *CircuitBreaker) processRecoveringState(ctx context.Context, res any, err error) (any, error) {
+	if inconclusive(ctx, err) {
+		// Caller gave up on the request or it never ran, dependency is not
+		// at fault
+		return nil, err
+	}
+
+	if err != nil {
+		// Dependency hasn't recovered after all, transition back to `open` state
+		cb.spendBudget(cb.clock.Now())
+		cb.reopen()
+		return res, err
+	}
+
+	cb.successCount++
+	slog.Debug("successful operation", "name", cb.name, "state", cb.loadState(), "count", cb.successCount)
+
+	if cb.successCount >= cb.recoveringSuccesses {
+		cb.recoverCircuit()
+	}
+
+	return res, nil
+}
@@ -0,0 +1,99 @@
+package circuitbreaker
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// makeRecovering drives `cb` through open and half-open into recovering state.
+func makeRecovering(t *testing.T, cb *CircuitBreaker, clock *FakeClock) {
+	t.Helper()
+
+	callOutcomes(cb, false)
+	clock.Advance(2 * time.Second)
+	// Transition to half open, successful probe moves on to recovering
+	callOutcomes(cb, true, true)
+	if cb.State() != StateRecovering {
+		t.Fatalf("recovered probe should move the breaker to recovering, got `%s`", cb.State())
+	}
+}
+
+func TestRecoveringState(t *testing.T) {
+	clock := NewFakeClock()
+	cb := NewCircuitBreaker(1, 1, time.Second, time.Second, WithClock(clock),
+		WithRand(rand.New(rand.NewSource(1))), WithRecoveringState(0.25, 5))
+	makeRecovering(t, cb, clock)
+
+	admitted, throttled := 0, 0
+	for cb.State() == StateRecovering && admitted+throttled < 1000 {
+		_, outcome, err := cb.CallDetailed(makeService(0, 1, 0))
+		switch {
+		case err == nil:
+			admitted++
+		case errors.Is(err, ErrThrottled) && outcome == OutcomeThrottled:
+			throttled++
+		default:
+			t.Fatalf("recovering breaker should either admit or throttle, got `%s`, `%v`", outcome, err)
+		}
+	}
+
+	if cb.State() != StateClosed || admitted != 5 {
+		t.Errorf("breaker should close after 5 successes, got `%s` after %d", cb.State(), admitted)
+	}
+	// Roughly a quarter of calls is admitted
+	if throttled < 5 || throttled > 40 {
+		t.Errorf("recovering breaker should throttle most calls, got %d throttled", throttled)
+	}
+}
+
+func TestAllowRequestRecovering(t *testing.T) {
+	newBreaker := func() (*CircuitBreaker, *FakeClock) {
+		clock := NewFakeClock()
+		cb := NewCircuitBreaker(1, 1, time.Second, time.Second, WithClock(clock),
+			WithRand(rand.New(rand.NewSource(1))), WithRecoveringState(0.25, 5))
+		makeRecovering(t, cb, clock)
+		return cb, clock
+	}
+
+	cb, _ := newBreaker()
+	asked, _ := newBreaker()
+	for i := 0; i < 20; i++ {
+		if !asked.AllowRequest() {
+			t.Fatal("recovering breaker should allow requests admitted by chance")
+		}
+
+		// Asking draws no sample, so both breakers admit the same calls
+		_, want, _ := cb.CallDetailed(makeService(0, 1, 0))
+		_, got, _ := asked.CallDetailed(makeService(0, 1, 0))
+		if got != want {
+			t.Fatalf("asking shouldn't affect admission of call %d, got `%s`, want `%s`", i, got, want)
+		}
+	}
+}
+
+func TestRecoveringStateReopens(t *testing.T) {
+	clock := NewFakeClock()
+	cb := NewCircuitBreaker(1, 1, time.Second, time.Second, WithClock(clock), WithRecoveringState(1, 5))
+	makeRecovering(t, cb, clock)
+
+	callOutcomes(cb, true, false)
+	if cb.State() != StateOpen {
+		t.Errorf("failure while recovering should re-open the breaker, got `%s`", cb.State())
+	}
+}
+
+func TestRecoveringStateTime(t *testing.T) {
+	clock := NewFakeClock()
+	cb := NewCircuitBreaker(1, 1, time.Second, time.Second, WithClock(clock), WithRecoveringState(1, 2))
+	makeRecovering(t, cb, clock)
+
+	clock.Advance(3 * time.Second)
+	callOutcomes(cb, true, true)
+	clock.Advance(time.Second)
+
+	if stats := cb.Stats(); stats.TimeRecovering != 3*time.Second || cb.State() != StateClosed {
+		t.Errorf("time in recovering state should be accounted, got `%s` in `%s`", stats.TimeRecovering, cb.State())
+	}
+}
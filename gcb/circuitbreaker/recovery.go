@@ -0,0 +1,56 @@
+package circuitbreaker
+
+// WithAutoHalfOpen schedules the transition from `open` to `half-open` state
+// on the clock once the breaker opens, so it happens when the recovery time
+// passes even if no call arrives. The next call is then admitted as a probe.
+// Transitions of a held breaker and of one with a health probe are left to
+// them.
+func WithAutoHalfOpen(enabled bool) Option {
+	return func(cb *CircuitBreaker) {
+		cb.autoHalfOpen = enabled
+	}
+}
+
+// scheduleRecovery schedules the transition to `half-open` state once the
+// recovery time of the current `open` state passes.
+func (cb *CircuitBreaker) scheduleRecovery() {
+	cb.cancelRecovery()
+
+	if !cb.autoHalfOpen || cb.healthProbe != nil || cb.held || cb.shutdown || cb.loadState() != StateOpen {
+		return
+	}
+
+	generation := cb.generation
+	cb.recoveryTimer = cb.clock.AfterFunc(cb.retryAfter(), func() {
+		cb.scheduledRecovery(generation)
+	})
+}
+
+// cancelRecovery cancels the scheduled transition to `half-open` state.
+func (cb *CircuitBreaker) cancelRecovery() {
+	if cb.recoveryTimer != nil {
+		cb.recoveryTimer.Stop()
+		cb.recoveryTimer = nil
+	}
+}
+
+// scheduledRecovery transitions the breaker to `half-open` state as long as
+// it stays in the `open` state of `generation`.
+func (cb *CircuitBreaker) scheduledRecovery(generation uint64) {
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	if cb.generation != generation || cb.shutdown || cb.held {
+		// Held breaker is rescheduled on release
+		return
+	}
+	cb.recoveryTimer = nil
+
+	if cb.retryAfter() > 0 {
+		// Last failure moved on, e.g. the breaker re-opened from `half-open`
+		cb.scheduleRecovery()
+		return
+	}
+
+	cb.toHalfOpen()
+}
@@ -0,0 +1,140 @@
+package circuitbreaker
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAutoHalfOpen(t *testing.T) {
+	clock := NewFakeClock()
+	cb := NewCircuitBreaker(1, 1, time.Second, time.Second, WithClock(clock), WithAutoHalfOpen(true))
+	defer cb.Close()
+
+	cb.Call(makeService(0, 1, 100))
+	if clock.Pending() != 1 {
+		t.Fatalf("opening should schedule the transition, got %d scheduled", clock.Pending())
+	}
+
+	clock.Advance(500 * time.Millisecond)
+	if cb.State() != StateOpen {
+		t.Fatalf("breaker shouldn't move to half open before the recovery time, got `%s`", cb.State())
+	}
+
+	clock.Advance(500 * time.Millisecond)
+	if cb.State() != StateHalfOpen {
+		t.Fatalf("breaker should move to half open without a call, got `%s`", cb.State())
+	}
+
+	// Next call is a probe rather than a rejection
+	_, outcome, err := cb.CallDetailed(makeService(0, 1, 0))
+	if outcome != OutcomeProbe || err != nil {
+		t.Errorf("call should be admitted as a probe, got `%s`, `%v`", outcome, err)
+	}
+	if cb.State() != StateClosed {
+		t.Errorf("successful probe should close the breaker, got `%s`", cb.State())
+	}
+}
+
+func TestAutoHalfOpenOncePerOpenPeriod(t *testing.T) {
+	logs := captureLogs(t)
+
+	clock := NewFakeClock()
+	cb := NewCircuitBreaker(1, 1, time.Second, time.Second, WithClock(clock), WithAutoHalfOpen(true))
+	defer cb.Close()
+
+	cb.Call(makeService(0, 1, 100))
+	for i := 0; i < 3; i++ {
+		clock.Advance(time.Second)
+		// Failed probe re-opens the breaker, rescheduling the transition
+		cb.Call(makeService(0, 1, 100))
+		if clock.Pending() != 1 {
+			t.Fatalf("each open period should have a single scheduled transition, got %d", clock.Pending())
+		}
+	}
+	clock.Advance(time.Second)
+
+	if n := strings.Count(logs.String(), "state transitioning to `half-open`"); n != 4 {
+		t.Errorf("transition should fire once per open period, got %d transitions", n)
+	}
+}
+
+func TestAutoHalfOpenCanceledByLazyTransition(t *testing.T) {
+	clock := NewFakeClock()
+	cb := NewCircuitBreaker(1, 1, time.Second, time.Second, WithClock(clock), WithAutoHalfOpen(true))
+	defer cb.Close()
+
+	cb.Call(makeService(0, 1, 100))
+	cb.Disable()
+	cb.Enable()
+
+	if clock.Pending() != 0 {
+		t.Errorf("leaving open state should cancel the scheduled transition, got %d scheduled", clock.Pending())
+	}
+}
+
+func TestAutoHalfOpenStaleTimer(t *testing.T) {
+	clock := NewFakeClock()
+	cb := NewCircuitBreaker(1, 1, time.Second, time.Second, WithClock(clock), WithAutoHalfOpen(true))
+	defer cb.Close()
+
+	cb.Call(makeService(0, 1, 100))
+	stale := cb.recoveryTimer
+
+	// Breaker opens again before the first transition is due
+	clock.Advance(500 * time.Millisecond)
+	cb.Disable()
+	cb.Enable()
+	cb.Call(makeService(0, 1, 100))
+
+	if stale.Stop() {
+		t.Error("transition of the previous open period should be canceled")
+	}
+
+	clock.Advance(500 * time.Millisecond)
+	if cb.State() != StateOpen {
+		t.Fatalf("breaker should stay open until its own recovery time, got `%s`", cb.State())
+	}
+
+	clock.Advance(500 * time.Millisecond)
+	if cb.State() != StateHalfOpen {
+		t.Errorf("rescheduled transition should move the breaker to half open, got `%s`", cb.State())
+	}
+}
+
+func TestAutoHalfOpenHeld(t *testing.T) {
+	clock := NewFakeClock()
+	cb := NewCircuitBreaker(1, 1, time.Second, time.Second, WithClock(clock), WithAutoHalfOpen(true))
+	defer cb.Close()
+
+	cb.Call(makeService(0, 1, 100))
+	cb.Hold()
+
+	clock.Advance(time.Minute)
+	if cb.State() != StateOpen {
+		t.Fatalf("held breaker shouldn't move to half open, got `%s`", cb.State())
+	}
+
+	cb.Release()
+	clock.Advance(0)
+	if cb.State() != StateHalfOpen {
+		t.Errorf("released breaker past its recovery time should move to half open, got `%s`", cb.State())
+	}
+}
+
+func TestAutoHalfOpenStopsOnClose(t *testing.T) {
+	clock := NewFakeClock()
+	cb := NewCircuitBreaker(1, 1, time.Second, time.Second, WithClock(clock), WithAutoHalfOpen(true))
+
+	cb.Call(makeService(0, 1, 100))
+	cb.Close()
+
+	if clock.Pending() != 0 {
+		t.Errorf("close should cancel the scheduled transition, got %d scheduled", clock.Pending())
+	}
+
+	clock.Advance(time.Second)
+	if cb.State() != StateOpen {
+		t.Errorf("closed breaker shouldn't transition, got `%s`", cb.State())
+	}
+}
@@ -0,0 +1,123 @@
+package circuitbreaker
+
+import (
+	"slices"
+	"sync"
+)
+
+// Registry keeps circuit breakers by name, e.g. one per downstream service.
+type Registry struct {
+	mu       sync.Mutex
+	breakers map[string]*CircuitBreaker
+	// Settings of breakers created by the registry
+	defaults Config
+	opts     []Option
+}
+
+// NewRegistry creates an empty registry. Breakers created by `GetOrCreate`
+// use `defaults` and `opts`, the config is expected to be valid.
+func NewRegistry(defaults Config, opts ...Option) *Registry {
+	return &Registry{
+		breakers: make(map[string]*CircuitBreaker),
+		defaults: defaults,
+		opts:     opts,
+	}
+}
+
+// Add registers `cb` under its name, replacing a breaker with the same name.
+func (r *Registry) Add(cb *CircuitBreaker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.breakers[cb.Name()] = cb
+}
+
+// Get returns the breaker registered under `name`.
+func (r *Registry) Get(name string) (*CircuitBreaker, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cb, ok := r.breakers[name]
+	return cb, ok
+}
+
+// GetOrCreate returns the breaker registered under `name`, creating it with
+// the registry defaults if there is none.
+func (r *Registry) GetOrCreate(name string) *CircuitBreaker {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.getOrCreate(name)
+}
+
+// getOrCreate is `GetOrCreate` for callers holding the lock.
+func (r *Registry) getOrCreate(name string) *CircuitBreaker {
+	if cb, ok := r.breakers[name]; ok {
+		return cb
+	}
+
+	cfg := r.defaults
+	cfg.Name = name
+	cb := NewCircuitBreaker(
+		cfg.FailureThreshold, cfg.HalfOpenThreshold,
+		cfg.RecoveryTime, cfg.Timeout,
+		append(cfg.options(), r.opts...)...,
+	)
+	r.breakers[name] = cb
+
+	return cb
+}
+
+// Names returns names of the registered breakers in sorted order.
+func (r *Registry) Names() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := make([]string, 0, len(r.breakers))
+	for name := range r.breakers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	return names
+}
+
+// Snapshots returns snapshots of the registered breakers by name.
+func (r *Registry) Snapshots() map[string]Snapshot {
+	r.mu.Lock()
+	breakers := make(map[string]*CircuitBreaker, len(r.breakers))
+	for name, cb := range r.breakers {
+		breakers[name] = cb
+	}
+	r.mu.Unlock()
+
+	snapshots := make(map[string]Snapshot, len(breakers))
+	for name, cb := range breakers {
+		snapshots[name] = cb.Snapshot()
+	}
+
+	return snapshots
+}
+
+// Export returns snapshots of the registered breakers by name, e.g. to move
+// them to another process with `Import`.
+func (r *Registry) Export() map[string]Snapshot {
+	return r.Snapshots()
+}
+
+// Import restores state and counters of the breakers named in `snapshots`,
+// creating missing ones with the registry defaults. Each breaker is restored
+// atomically, calls see either its state before the import or after it.
+func (r *Registry) Import(snapshots map[string]Snapshot) {
+	r.mu.Lock()
+	breakers := make(map[string]*CircuitBreaker, len(snapshots))
+	for name := range snapshots {
+		breakers[name] = r.getOrCreate(name)
+	}
+	r.mu.Unlock()
+
+	// Restored outside of the registry lock, state change callbacks may use it
+	for name, cb := range breakers {
+		cb.restore(snapshots[name])
+	}
+}
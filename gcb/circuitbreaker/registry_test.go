@@ -0,0 +1,117 @@
+package circuitbreaker
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+var registryDefaults = Config{
+	FailureThreshold:  1,
+	HalfOpenThreshold: 1,
+	RecoveryTime:      time.Minute,
+	Timeout:           time.Second,
+}
+
+func TestRegistryGetOrCreate(t *testing.T) {
+	reg := NewRegistry(registryDefaults)
+
+	cb := reg.GetOrCreate("payments")
+	if cb.Name() != "payments" {
+		t.Errorf("created breaker should be named `payments`, got `%s`", cb.Name())
+	}
+	if got := cb.Config().RecoveryTime; got != time.Minute {
+		t.Errorf("created breaker should use registry defaults, got recovery time `%s`", got)
+	}
+
+	if again := reg.GetOrCreate("payments"); again != cb {
+		t.Error("registry should return the existing breaker")
+	}
+
+	got, ok := reg.Get("payments")
+	if !ok || got != cb {
+		t.Error("created breaker should be registered")
+	}
+
+	if _, ok := reg.Get("unknown"); ok {
+		t.Error("unknown breaker shouldn't be found")
+	}
+}
+
+func TestRegistryAdd(t *testing.T) {
+	reg := NewRegistry(registryDefaults)
+
+	cb := NewCircuitBreaker(3, 1, time.Second, time.Second, WithName("search"))
+	reg.Add(cb)
+	reg.GetOrCreate("auth")
+
+	if got := reg.GetOrCreate("search"); got != cb {
+		t.Error("added breaker should be returned by name")
+	}
+
+	if names := reg.Names(); !slices.Equal(names, []string{"auth", "search"}) {
+		t.Errorf("names should be sorted `[auth search]`, got `%v`", names)
+	}
+}
+
+func TestRegistryExportImport(t *testing.T) {
+	reg := NewRegistry(Config{
+		FailureThreshold:  2,
+		HalfOpenThreshold: 2,
+		RecoveryTime:      time.Minute,
+		Timeout:           time.Second,
+	})
+
+	// Closed with a failure counted towards opening
+	callOutcomes(reg.GetOrCreate("auth"), false)
+	callOutcomes(reg.GetOrCreate("payments"), false, false)
+	search := NewCircuitBreaker(1, 2, time.Millisecond, time.Second, WithName("search"))
+	reg.Add(search)
+	makeHalfOpen(t, search)
+	callOutcomes(search, true)
+
+	exported := reg.Export()
+
+	fresh := NewRegistry(registryDefaults)
+	fresh.Import(exported)
+
+	if names := fresh.Names(); !slices.Equal(names, []string{"auth", "payments", "search"}) {
+		t.Fatalf("import should create unknown breakers, got `%v`", names)
+	}
+
+	for name, want := range exported {
+		cb, _ := fresh.Get(name)
+		got := cb.Snapshot()
+		if got.State != want.State || got.FailureCount != want.FailureCount || got.SuccessCount != want.SuccessCount {
+			t.Errorf("breaker `%s` should be restored as `%+v`, got `%+v`", name, want, got)
+		}
+	}
+
+	payments, _ := fresh.Get("payments")
+	if left := payments.RetryAfter(); left <= 59*time.Second || left > time.Minute {
+		t.Errorf("open breaker should keep its time left, got `%s`", left)
+	}
+	if _, err := payments.Call(makeService(1, 2, 0)); !errors.Is(err, ErrOpenState) {
+		t.Errorf("restored open breaker should reject calls, got `%v`", err)
+	}
+}
+
+func TestRegistryImportExisting(t *testing.T) {
+	reg := NewRegistry(registryDefaults)
+	cb := reg.GetOrCreate("payments")
+
+	reg.Import(map[string]Snapshot{
+		"payments": {State: StateOpen, FailureCount: 1, RetryAfter: time.Second},
+	})
+
+	if got, _ := reg.Get("payments"); got != cb {
+		t.Error("import should restore the registered breaker in place")
+	}
+	if cb.State() != StateOpen {
+		t.Errorf("breaker should be restored to `open`, got `%s`", cb.State())
+	}
+	if left := cb.RetryAfter(); left <= 0 || left > time.Second {
+		t.Errorf("time left should be taken from the snapshot, got `%s`", left)
+	}
+}
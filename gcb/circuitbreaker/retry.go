@@ -0,0 +1,39 @@
+package circuitbreaker
+
+import (
+	"context"
+	"time"
+)
+
+// WithRetry retries a failing operation up to `maxRetries` times before its
+// outcome is accounted for, so only the final attempt counts as a success or
+// a failure. Delay between attempts starts at `backoff` and doubles after
+// each attempt. All attempts share the timeout of a single call.
+func WithRetry(maxRetries int, backoff time.Duration) Option {
+	return func(cb *CircuitBreaker) {
+		cb.maxRetries = maxRetries
+		cb.retryBackoff = backoff
+	}
+}
+
+// retry runs `fn` until it succeeds, retries are exhausted or `ctx` is done.
+func (cb *CircuitBreaker) retry(ctx context.Context, fn operation) (any, error) {
+	delay := cb.retryBackoff
+
+	for attempt := 0; ; attempt++ {
+		res, err := fn()
+		if err == nil || attempt >= cb.maxRetries {
+			return res, err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return res, err
+		case <-timer.C:
+		}
+
+		delay *= 2
+	}
+}
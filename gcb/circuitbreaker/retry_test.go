@@ -0,0 +1,76 @@
+package circuitbreaker
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyService fails `failures` times before it starts succeeding
+func flakyService(failures int32, calls *atomic.Int32) operation {
+	return func() (any, error) {
+		if calls.Add(1) <= failures {
+			return nil, errors.New("service failed")
+		}
+		return "OK", nil
+	}
+}
+
+func TestRetryRecordsFinalOutcome(t *testing.T) {
+	cb := NewCircuitBreaker(1, 1, 2*time.Second, time.Second, WithRetry(2, 5*time.Millisecond), WithHistory(5))
+
+	var calls atomic.Int32
+	res, err := cb.Call(flakyService(2, &calls))
+	if err != nil || res != "OK" {
+		t.Errorf("operation should succeed on the third attempt, got `%v`, `%v`", res, err)
+	}
+
+	if n := calls.Load(); n != 3 {
+		t.Errorf("operation should be attempted 3 times, got `%d`", n)
+	}
+
+	if cb.State() != StateClosed || cb.failureCount != 0 {
+		t.Errorf("retried failures shouldn't count, got `%s` with `%d` failures", cb.State(), cb.failureCount)
+	}
+
+	history := cb.History()
+	if len(history) != 1 || history[0].Err != nil {
+		t.Errorf("breaker should record a single success, got `%+v`", history)
+	}
+}
+
+func TestRetryExhausted(t *testing.T) {
+	cb := NewCircuitBreaker(1, 1, 2*time.Second, time.Second, WithRetry(2, 5*time.Millisecond))
+
+	var calls atomic.Int32
+	_, err := cb.Call(flakyService(10, &calls))
+	if err == nil {
+		t.Errorf("operation should fail once retries are exhausted, got `nil`")
+	}
+
+	if n := calls.Load(); n != 3 {
+		t.Errorf("operation should be attempted 3 times, got `%d`", n)
+	}
+
+	if cb.State() != StateOpen {
+		t.Errorf("final failure should open the breaker, got `%s`", cb.State())
+	}
+}
+
+func TestRetryRespectsTimeout(t *testing.T) {
+	cb := NewCircuitBreaker(1, 1, 2*time.Second, 60*time.Millisecond, WithRetry(10, 50*time.Millisecond))
+
+	var calls atomic.Int32
+	_, err := cb.Call(flakyService(100, &calls))
+	if !errors.Is(err, ErrTimeout) {
+		t.Errorf("retries should be cut off by the timeout, got `%v`", err)
+	}
+
+	// No more attempts after the call timed out
+	attempts := calls.Load()
+	time.Sleep(150 * time.Millisecond)
+	if n := calls.Load(); n != attempts || n > 2 {
+		t.Errorf("retries should stop at the timeout, got `%d` attempts", n)
+	}
+}
@@ -0,0 +1,82 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+)
+
+// ShardedBreaker fronts a dependency sharded across backends with a circuit
+// breaker per shard.
+type ShardedBreaker struct {
+	// Number of shards tried after the primary one rejects a call
+	replicas int
+	shards   []*CircuitBreaker
+}
+
+// NewShardedBreaker creates a sharded breaker with a breaker per shard. Calls
+// rejected by the primary shard are retried on up to `replicas` following
+// shards, zero disables the fallback.
+func NewShardedBreaker(replicas int, shards ...*CircuitBreaker) *ShardedBreaker {
+	return &ShardedBreaker{
+		replicas: replicas,
+		shards:   shards,
+	}
+}
+
+// Shard returns the primary shard of `key`, -1 if there are no shards.
+func (s *ShardedBreaker) Shard(key string) int {
+	if len(s.shards) == 0 {
+		return -1
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(key))
+
+	return int(h.Sum32() % uint32(len(s.shards)))
+}
+
+// Breaker returns the breaker of `shard`, `nil` if there is no such shard.
+func (s *ShardedBreaker) Breaker(shard int) *CircuitBreaker {
+	if shard < 0 || shard >= len(s.shards) {
+		return nil
+	}
+
+	return s.shards[shard]
+}
+
+// Call runs `fn` through the breaker of the primary shard of `key`, passing
+// it the shard to use. When the breaker rejects the call it's retried on the
+// next shards, up to the number of replicas.
+func (s *ShardedBreaker) Call(key string, fn func(shard int) (any, error)) (any, error) {
+	if len(s.shards) == 0 {
+		return nil, &BreakerError{Name: "sharded", State: StateOpen, Err: ErrOpenState}
+	}
+
+	primary := s.Shard(key)
+
+	var err error
+	for i := 0; i <= s.replicas && i < len(s.shards); i++ {
+		shard := (primary + i) % len(s.shards)
+		var op operation
+		if fn != nil {
+			// Nil operation is refused by the breaker
+			op = func() (any, error) {
+				return fn(shard)
+			}
+		}
+
+		res, outcome, shardErr := s.shards[shard].call(context.Background(), "", op)
+
+		var be *BreakerError
+		if outcome == OutcomeTransitioned || errors.As(shardErr, &be) {
+			// Shard didn't run the operation, try the replica
+			err = shardErr
+			continue
+		}
+
+		return res, shardErr
+	}
+
+	return nil, err
+}
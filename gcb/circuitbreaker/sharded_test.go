@@ -0,0 +1,141 @@
+package circuitbreaker
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func shardBreakers(n int) []*CircuitBreaker {
+	shards := make([]*CircuitBreaker, n)
+	for i := range shards {
+		shards[i] = NewCircuitBreaker(1, 1, time.Minute, time.Second, WithName(fmt.Sprintf("shard-%d", i)))
+	}
+	return shards
+}
+
+// keyOf finds a key which maps to `shard`
+func keyOf(t *testing.T, s *ShardedBreaker, shard int) string {
+	t.Helper()
+
+	for i := 0; i < 1000; i++ {
+		if key := fmt.Sprintf("user-%d", i); s.Shard(key) == shard {
+			return key
+		}
+	}
+
+	t.Fatalf("no key maps to shard %d", shard)
+	return ""
+}
+
+func shardService(shard int) (any, error) {
+	return shard, nil
+}
+
+func TestShardedBreakerPrimary(t *testing.T) {
+	s := NewShardedBreaker(1, shardBreakers(3)...)
+
+	for shard := 0; shard < 3; shard++ {
+		key := keyOf(t, s, shard)
+		if s.Shard(key) != s.Shard(key) {
+			t.Fatal("key should map to the same shard")
+		}
+
+		res, err := s.Call(key, shardService)
+		if err != nil || res != shard {
+			t.Errorf("key should be served by shard %d, got `%v`, `%v`", shard, res, err)
+		}
+	}
+}
+
+func TestShardedBreakerReroutes(t *testing.T) {
+	s := NewShardedBreaker(1, shardBreakers(3)...)
+	key := keyOf(t, s, 1)
+
+	// Force the primary shard open
+	s.Breaker(1).Call(makeService(0, 1, 100))
+	if s.Breaker(1).State() != StateOpen {
+		t.Fatalf("primary shard should be open, got `%s`", s.Breaker(1).State())
+	}
+
+	res, err := s.Call(key, shardService)
+	if err != nil || res != 2 {
+		t.Errorf("traffic should reroute to the replica shard 2, got `%v`, `%v`", res, err)
+	}
+
+	if got := s.Breaker(0).Stats().Requests; got != 0 {
+		t.Errorf("shards past the replicas shouldn't be used, got %d requests", got)
+	}
+}
+
+func TestShardedBreakerReplicasExhausted(t *testing.T) {
+	s := NewShardedBreaker(1, shardBreakers(3)...)
+	key := keyOf(t, s, 2)
+
+	s.Breaker(2).Call(makeService(0, 1, 100))
+	s.Breaker(0).Call(makeService(0, 1, 100))
+
+	_, err := s.Call(key, shardService)
+	var be *BreakerError
+	if !errors.As(err, &be) || be.Name != "shard-0" || !errors.Is(err, ErrOpenState) {
+		t.Errorf("call should be rejected by the last tried replica, got `%v`", err)
+	}
+}
+
+func TestShardedBreakerNoReplicas(t *testing.T) {
+	s := NewShardedBreaker(0, shardBreakers(2)...)
+	key := keyOf(t, s, 0)
+
+	s.Breaker(0).Call(makeService(0, 1, 100))
+
+	if _, err := s.Call(key, shardService); !errors.Is(err, ErrOpenState) {
+		t.Errorf("call should be rejected without fallback, got `%v`", err)
+	}
+}
+
+func TestShardedBreakerFailureNotRerouted(t *testing.T) {
+	s := NewShardedBreaker(1, shardBreakers(2)...)
+	key := keyOf(t, s, 0)
+
+	calls := 0
+	_, err := s.Call(key, func(shard int) (any, error) {
+		calls++
+		return nil, errors.New("backend failed")
+	})
+
+	if err == nil || calls != 1 {
+		t.Errorf("failure of an executed call shouldn't be retried, got %d calls, `%v`", calls, err)
+	}
+}
+
+func TestShardedBreakerNoShards(t *testing.T) {
+	s := NewShardedBreaker(1)
+
+	if shard := s.Shard("user-1"); shard != -1 {
+		t.Errorf("key shouldn't map to a shard without shards, got %d", shard)
+	}
+	if cb := s.Breaker(s.Shard("user-1")); cb != nil {
+		t.Errorf("missing shard shouldn't have a breaker, got `%s`", cb.Name())
+	}
+
+	_, err := s.Call("user-1", func(int) (any, error) { return "OK", nil })
+	if !errors.Is(err, ErrOpenState) {
+		t.Errorf("call without shards should be rejected, got `%v`", err)
+	}
+}
+
+func TestShardedBreakerNilOperation(t *testing.T) {
+	shards := shardBreakers(2)
+	s := NewShardedBreaker(1, shards...)
+
+	if _, err := s.Call("user-1", nil); !errors.Is(err, ErrNilOperation) {
+		t.Errorf("nil operation should be refused, got `%v`", err)
+	}
+
+	for _, cb := range shards {
+		if cb.State() != StateClosed || cb.Stats().Counts != (Counts{}) {
+			t.Errorf("nil operation shouldn't be accounted, got `%s` with `%+v`", cb.State(), cb.Stats().Counts)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package circuitbreaker
+
+import "time"
+
+// Step is a single scripted call in a simulation.
+type Step struct {
+	// Outcome is the error returned by the operation, `nil` for a success
+	Outcome error
+	// AdvanceClock is the time passing before the call is made
+	AdvanceClock time.Duration
+}
+
+// StepResult is the effect of a single step of a simulation.
+type StepResult struct {
+	// Before is the state of the breaker once the clock advanced, before
+	// the call is made
+	Before State
+	// After is the state of the breaker once the call returned
+	After State
+	// Executed reports whether the operation ran
+	Executed bool
+	Outcome  CallOutcome
+	Err      error
+}
+
+// Simulate drives `cb` through scripted calls, advancing `clock` by the time
+// of each step before making its call, and returns the result of every step.
+// The breaker has to be created with `WithClock(clock)`. Runs are
+// deterministic as long as the breaker doesn't run background work on the
+// wall clock, e.g. retry backoff.
+func Simulate(cb *CircuitBreaker, clock *FakeClock, steps []Step) []StepResult {
+	results := make([]StepResult, 0, len(steps))
+
+	for _, step := range steps {
+		clock.Advance(step.AdvanceClock)
+
+		r := StepResult{Before: cb.State()}
+		outcome := step.Outcome
+		_, r.Outcome, r.Err = cb.CallDetailed(func() (any, error) {
+			r.Executed = true
+			return nil, outcome
+		})
+		r.After = cb.State()
+
+		results = append(results, r)
+	}
+
+	return results
+}
@@ -0,0 +1,136 @@
+package circuitbreaker
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// Settings of breakers checked against invariants
+const (
+	simFailureThreshold  = 3
+	simHalfOpenThreshold = 2
+	simRecoveryTime      = time.Second
+)
+
+var errSimulated = errors.New("simulated failure")
+
+func simulatedBreaker() (*CircuitBreaker, *FakeClock) {
+	clock := NewFakeClock()
+	cb := NewCircuitBreaker(simFailureThreshold, simHalfOpenThreshold, simRecoveryTime, time.Second, WithClock(clock))
+	return cb, clock
+}
+
+// checkInvariants verifies results of `steps` hold regardless of the script.
+func checkInvariants(t *testing.T, steps []Step, results []StepResult) {
+	t.Helper()
+
+	var now, openedAt time.Duration
+	probeSuccesses := 0
+	for i, r := range results {
+		now += steps[i].AdvanceClock
+
+		if r.Before == StateOpen && now-openedAt < simRecoveryTime && r.Executed {
+			t.Fatalf("step %d: operation executed while open", i)
+		}
+
+		if r.Outcome == OutcomeProbe {
+			if r.Err == nil {
+				probeSuccesses++
+			} else {
+				probeSuccesses = 0
+			}
+		}
+		if r.Before != StateClosed && r.After == StateClosed && probeSuccesses < simHalfOpenThreshold {
+			t.Fatalf("step %d: closed after %d successful probes", i, probeSuccesses)
+		}
+
+		if r.After == StateOpen && (r.Before != StateOpen || r.Executed) {
+			openedAt = now
+			probeSuccesses = 0
+		}
+		if r.After == StateClosed {
+			probeSuccesses = 0
+		}
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	cb, clock := simulatedBreaker()
+
+	steps := []Step{
+		{Outcome: errSimulated},
+		{Outcome: errSimulated},
+		{Outcome: errSimulated},
+		// Rejected without executing
+		{AdvanceClock: 500 * time.Millisecond},
+		// Recovery time passed, the call moves the breaker to half open
+		{AdvanceClock: 600 * time.Millisecond},
+		// Probes close the breaker
+		{},
+		{},
+	}
+	results := Simulate(cb, clock, steps)
+
+	want := []struct {
+		after    State
+		executed bool
+		outcome  CallOutcome
+	}{
+		{StateClosed, true, OutcomeExecuted},
+		{StateClosed, true, OutcomeExecuted},
+		{StateOpen, true, OutcomeExecuted},
+		{StateOpen, false, OutcomeRejectedOpen},
+		{StateHalfOpen, false, OutcomeTransitioned},
+		{StateHalfOpen, true, OutcomeProbe},
+		{StateClosed, true, OutcomeProbe},
+	}
+	for i, r := range results {
+		if r.After != want[i].after || r.Executed != want[i].executed || r.Outcome != want[i].outcome {
+			t.Errorf("step %d should end `%s`, executed %t, `%s`, got `%+v`",
+				i, want[i].after, want[i].executed, want[i].outcome, r)
+		}
+	}
+}
+
+func TestSimulateInvariants(t *testing.T) {
+	captureLogs(t)
+
+	for seed := int64(0); seed < 20; seed++ {
+		rng := rand.New(rand.NewSource(seed))
+		steps := make([]Step, 1000)
+		for i := range steps {
+			if rng.Intn(2) == 0 {
+				steps[i].Outcome = errSimulated
+			}
+			steps[i].AdvanceClock = time.Duration(rng.Intn(400)) * time.Millisecond
+		}
+
+		cb, clock := simulatedBreaker()
+		checkInvariants(t, steps, Simulate(cb, clock, steps))
+	}
+}
+
+// FuzzSimulate reads each byte of the input as a step, the lowest bit fails
+// the call and the rest advances the clock in 20ms increments.
+func FuzzSimulate(f *testing.F) {
+	f.Add([]byte{1, 1, 1, 0, 100, 0, 0})
+	f.Add([]byte{1, 1, 1, 101, 1, 101, 0, 0, 1})
+	f.Add([]byte{0, 255, 1, 255, 1, 1, 1, 1})
+
+	f.Fuzz(func(t *testing.T, script []byte) {
+		captureLogs(t)
+
+		steps := make([]Step, len(script))
+		for i, b := range script {
+			if b&1 == 1 {
+				steps[i].Outcome = errSimulated
+			}
+			steps[i].AdvanceClock = time.Duration(b>>1) * 20 * time.Millisecond
+		}
+
+		cb, clock := simulatedBreaker()
+		checkInvariants(t, steps, Simulate(cb, clock, steps))
+	})
+}
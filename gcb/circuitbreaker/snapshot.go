@@ -0,0 +1,133 @@
+package circuitbreaker
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Snapshot is a point in time view of the circuit breaker.
+//
+// JSON encoding uses the following fields:
+//   - `state`: name of the state, one of `closed`, `open`, `half-open`,
+//     `recovering`
+//   - `failure_count`: consecutive failures counted towards opening
+//   - `success_count`: successful requests made in `half-open` state
+//   - `last_failure_time`: RFC 3339 time of the last failure, omitted if none
+//   - `retry_after`: time left until recovery attempt, e.g. `"1.5s"`
+type Snapshot struct {
+	State           State
+	FailureCount    int
+	SuccessCount    int
+	LastFailureTime time.Time
+	RetryAfter      time.Duration
+}
+
+// MarshalJSON encodes the snapshot using documented field names.
+func (s Snapshot) MarshalJSON() ([]byte, error) {
+	type snapshotJSON struct {
+		State           State      `json:"state"`
+		FailureCount    int        `json:"failure_count"`
+		SuccessCount    int        `json:"success_count"`
+		LastFailureTime *time.Time `json:"last_failure_time,omitempty"`
+		RetryAfter      string     `json:"retry_after"`
+	}
+
+	out := snapshotJSON{
+		State:        s.State,
+		FailureCount: s.FailureCount,
+		SuccessCount: s.SuccessCount,
+		RetryAfter:   s.RetryAfter.String(),
+	}
+	if !s.LastFailureTime.IsZero() {
+		out.LastFailureTime = &s.LastFailureTime
+	}
+
+	return json.Marshal(out)
+}
+
+// State returns the current state of the circuit breaker. It doesn't take
+// the lock, so it's cheap enough for hot paths.
+func (cb *CircuitBreaker) State() State {
+	return cb.loadState()
+}
+
+// Name returns the name the circuit breaker was created with.
+func (cb *CircuitBreaker) Name() string {
+	return cb.name
+}
+
+// Snapshot returns the current state and counters of the circuit breaker.
+func (cb *CircuitBreaker) Snapshot() Snapshot {
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	cb.evictFailures()
+
+	return Snapshot{
+		State:           cb.loadState(),
+		FailureCount:    cb.failureCount,
+		SuccessCount:    cb.successCount,
+		LastFailureTime: cb.lastFailureTime,
+		RetryAfter:      cb.retryAfter(),
+	}
+}
+
+// RetryAfter returns time left before the breaker in `open` state allows a
+// recovery attempt, zero in other states.
+func (cb *CircuitBreaker) RetryAfter() time.Duration {
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	return cb.retryAfter()
+}
+
+// retryAfter returns time left before `open` state allows a recovery attempt.
+func (cb *CircuitBreaker) retryAfter() time.Duration {
+	if cb.loadState() != StateOpen {
+		return 0
+	}
+
+	left := cb.cycleRecoveryTime - cb.clock.Now().Sub(cb.lastFailureTime)
+	// Error budget has to drain as well
+	left = max(left, cb.budgetDrainsIn())
+	if left < 0 {
+		return 0
+	}
+
+	return left
+}
+
+// restore puts the circuit breaker into the state and counters of `s`. Time
+// left in `open` state is taken from `s.RetryAfter` rather than the failure
+// time, so the breaker recovers on schedule even if the snapshot was taken
+// on another host. `recovering` state falls back to `half-open` unless
+// configured.
+func (cb *CircuitBreaker) restore(s Snapshot) {
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	state := s.State
+	if state == StateRecovering && cb.recoveringFraction <= 0 {
+		state = StateHalfOpen
+	}
+	if state != cb.loadState() {
+		cb.setState(state)
+	}
+
+	cb.failureCount = s.FailureCount
+	cb.failures = nil
+	cb.timeoutCount = 0
+	cb.successCount = s.SuccessCount
+	// Failed attempts aren't part of the snapshot
+	cb.halfOpenAttempts = s.SuccessCount
+	cb.lastFailureTime = s.LastFailureTime
+	if cb.failureTTL > 0 && s.FailureCount > 0 {
+		cb.failures = []failureRecord{{s.LastFailureTime, s.FailureCount}}
+		cb.evictFailures()
+	}
+
+	if state == StateOpen {
+		cb.lastFailureTime = cb.clock.Now().Add(s.RetryAfter - cb.cycleRecoveryTime)
+		cb.scheduleRecovery()
+	}
+}
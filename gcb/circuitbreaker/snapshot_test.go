@@ -0,0 +1,149 @@
+package circuitbreaker
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStateString(t *testing.T) {
+	cases := map[State]string{
+		StateClosed:   "closed",
+		StateOpen:     "open",
+		StateHalfOpen: "half-open",
+		State(42):     "unknown(42)",
+	}
+
+	for state, want := range cases {
+		if got := fmt.Sprint(state); got != want {
+			t.Errorf("state should print as `%s`, got `%s`", want, got)
+		}
+	}
+}
+
+func TestStateMarshalJSON(t *testing.T) {
+	cases := map[State]string{
+		StateClosed:   `"closed"`,
+		StateOpen:     `"open"`,
+		StateHalfOpen: `"half-open"`,
+	}
+
+	for state, want := range cases {
+		got, err := json.Marshal(state)
+		if err != nil {
+			t.Fatalf("state should marshal, got `%s` error", err)
+		}
+
+		if string(got) != want {
+			t.Errorf("state should marshal to `%s`, got `%s`", want, got)
+		}
+	}
+}
+
+func TestSnapshotMarshalJSON(t *testing.T) {
+	lastFailure := time.Date(2024, 11, 5, 10, 30, 0, 0, time.UTC)
+	s := Snapshot{
+		State:           StateOpen,
+		FailureCount:    3,
+		SuccessCount:    0,
+		LastFailureTime: lastFailure,
+		RetryAfter:      1500 * time.Millisecond,
+	}
+
+	got, err := json.Marshal(s)
+	if err != nil {
+		t.Fatalf("snapshot should marshal, got `%s` error", err)
+	}
+
+	want := `{"state":"open","failure_count":3,"success_count":0,` +
+		`"last_failure_time":"2024-11-05T10:30:00Z","retry_after":"1.5s"}`
+	if string(got) != want {
+		t.Errorf("snapshot should marshal to `%s`, got `%s`", want, got)
+	}
+}
+
+func TestSnapshotMarshalJSONNoFailure(t *testing.T) {
+	cb := NewCircuitBreaker(2, 2, 2*time.Second, 2*time.Second)
+
+	got, err := json.Marshal(cb.Snapshot())
+	if err != nil {
+		t.Fatalf("snapshot should marshal, got `%s` error", err)
+	}
+
+	want := `{"state":"closed","failure_count":0,"success_count":0,"retry_after":"0s"}`
+	if string(got) != want {
+		t.Errorf("snapshot should marshal to `%s`, got `%s`", want, got)
+	}
+}
+
+func TestSnapshotOpenState(t *testing.T) {
+	cb := NewCircuitBreaker(1, 1, 2*time.Second, 2*time.Second)
+	// Always failing service
+	cb.Call(makeService(20, 200, 100))
+
+	s := cb.Snapshot()
+	if s.State != StateOpen {
+		t.Errorf("snapshot state should be open, got `%s`", s.State)
+	}
+
+	if s.FailureCount != 1 {
+		t.Errorf("snapshot should count one failure, got `%d`", s.FailureCount)
+	}
+
+	if s.RetryAfter <= 0 || s.RetryAfter > 2*time.Second {
+		t.Errorf("retry after should be within recovery time, got `%s`", s.RetryAfter)
+	}
+
+	got, err := json.Marshal(s)
+	if err != nil {
+		t.Fatalf("snapshot should marshal, got `%s` error", err)
+	}
+
+	if !strings.Contains(string(got), `"last_failure_time":`) {
+		t.Errorf("snapshot should contain last failure time, got `%s`", got)
+	}
+}
+
+func TestStateConcurrentReads(t *testing.T) {
+	cb := NewCircuitBreaker(2, 1, 5*time.Millisecond, time.Second)
+	service := makeService(1, 3, 50)
+
+	stop := make(chan struct{})
+	var readers sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+
+				if s := cb.State(); s < StateClosed || s > StateHalfOpen {
+					t.Errorf("state should be valid, got `%d`", s)
+					return
+				}
+			}
+		}()
+	}
+
+	var callers sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		callers.Add(1)
+		go func() {
+			defer callers.Done()
+			for j := 0; j < 50; j++ {
+				cb.Call(service)
+			}
+		}()
+	}
+
+	callers.Wait()
+	close(stop)
+	readers.Wait()
+}
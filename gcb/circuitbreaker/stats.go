@@ -0,0 +1,253 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+// Counts of calls made through the circuit breaker.
+type Counts struct {
+	// Calls which executed the operation
+	Requests int
+	// Executed calls which succeeded
+	Successes int
+	// Executed calls which failed, caller cancellations excluded
+	Failures int
+	// Calls shed without executing the operation
+	Rejections int
+}
+
+// add accumulates `other` into the counts.
+func (c *Counts) add(other Counts) {
+	c.Requests += other.Requests
+	c.Successes += other.Successes
+	c.Failures += other.Failures
+	c.Rejections += other.Rejections
+}
+
+// FailureReason classifies why an executed call failed.
+type FailureReason int
+
+const (
+	// FailureOperationError is an error returned by the operation
+	FailureOperationError FailureReason = iota
+	// FailureTimeout is the operation not completing within the timeout
+	FailureTimeout
+	// FailurePanic is a panic of the operation
+	FailurePanic
+)
+
+// String returns human readable name of the reason.
+func (r FailureReason) String() string {
+	switch r {
+	case FailureOperationError:
+		return "operation-error"
+	case FailureTimeout:
+		return "timeout"
+	case FailurePanic:
+		return "panic"
+	default:
+		return "unknown"
+	}
+}
+
+// failureReason classifies the error of a failed call.
+func failureReason(err error) FailureReason {
+	switch {
+	case errors.Is(err, ErrPanic):
+		return FailurePanic
+	case errors.Is(err, ErrTimeout):
+		return FailureTimeout
+	default:
+		return FailureOperationError
+	}
+}
+
+// Stats are cumulative counts of calls made through the circuit breaker.
+type Stats struct {
+	// Counts of all calls
+	Counts
+	// Counts of calls made with `CallTagged` by tag
+	ByTag map[string]Counts
+	// Failures of all calls by reason
+	FailuresByReason map[FailureReason]int
+	// Moving average latency of successful calls, zero unless enabled
+	AvgLatency time.Duration
+	// Successful calls by latency bucket, e.g. `"<50ms"` or `">=500ms"`, `nil`
+	// unless enabled
+	LatencyBuckets map[string]int
+	// Cumulative time spent in each state, including the current one
+	TimeClosed     time.Duration
+	TimeOpen       time.Duration
+	TimeHalfOpen   time.Duration
+	TimeRecovering time.Duration
+}
+
+// callStats accumulates counts of calls.
+type callStats struct {
+	total Counts
+	byTag map[string]Counts
+	// Failures by reason
+	byReason map[FailureReason]int
+	// Untagged rejections made without the lock
+	fastRejections atomic.Int64
+	// Smoothing factor of the average latency, zero means disabled
+	alpha float64
+	// Exponentially weighted moving average latency of successful calls
+	avgLatency time.Duration
+	// Upper bounds of latency buckets in ascending order, `nil` disables them
+	bucketBounds []time.Duration
+	// Successful calls by latency bucket, the last one is above all bounds
+	buckets []int
+	// Time spent in each state before entering the current one, by state
+	timeInState [StateRecovering + 1]time.Duration
+	// Time the current state was entered
+	stateSince time.Time
+}
+
+// add accounts `c` globally and under `tag` unless it's empty.
+func (s *callStats) add(tag string, c Counts) {
+	s.total.add(c)
+	if tag == "" {
+		return
+	}
+
+	if s.byTag == nil {
+		s.byTag = make(map[string]Counts)
+	}
+	counts := s.byTag[tag]
+	counts.add(c)
+	s.byTag[tag] = counts
+}
+
+// addFailure accounts a failed call under its reason.
+func (s *callStats) addFailure(reason FailureReason) {
+	if s.byReason == nil {
+		s.byReason = make(map[FailureReason]int)
+	}
+	s.byReason[reason]++
+}
+
+// leaveState accounts the time spent in `from` state, which is left `now`.
+func (s *callStats) leaveState(from State, now time.Time) {
+	s.timeInState[from] += now.Sub(s.stateSince)
+	s.stateSince = now
+}
+
+// observeLatency folds latency of a successful call into the average and
+// tallies it into its bucket.
+func (s *callStats) observeLatency(d time.Duration) {
+	if s.buckets != nil {
+		i, found := slices.BinarySearch(s.bucketBounds, d)
+		if found {
+			// Bounds are exclusive
+			i++
+		}
+		s.buckets[i]++
+	}
+
+	if s.alpha <= 0 {
+		return
+	}
+
+	if s.avgLatency == 0 {
+		s.avgLatency = d
+		return
+	}
+
+	s.avgLatency += time.Duration(s.alpha * float64(d-s.avgLatency))
+}
+
+// executionCounts counts an executed call which returned `err`.
+func executionCounts(ctx context.Context, err error) Counts {
+	switch {
+	case err == nil:
+		return Counts{Requests: 1, Successes: 1}
+	case isCanceled(ctx, err):
+		return Counts{Requests: 1}
+	case notStarted(err):
+		// Operation never ran
+		return Counts{Rejections: 1}
+	default:
+		return Counts{Requests: 1, Failures: 1}
+	}
+}
+
+// WithLatencyEWMA tracks an exponentially weighted moving average of the
+// latency of successful calls, reported as `Stats().AvgLatency`. `alpha`
+// within (0, 1] is the weight of the latest call, higher values follow
+// changes faster.
+func WithLatencyEWMA(alpha float64) Option {
+	return func(cb *CircuitBreaker) {
+		cb.stats.alpha = alpha
+	}
+}
+
+// WithLatencyBuckets tallies successful calls into latency buckets separated
+// by `bounds`, reported as `Stats().LatencyBuckets`. Bounds of 50ms and 100ms
+// make buckets `"<50ms"`, `"<100ms"` and `">=100ms"`. No bounds disable the
+// buckets.
+func WithLatencyBuckets(bounds []time.Duration) Option {
+	return func(cb *CircuitBreaker) {
+		if len(bounds) == 0 {
+			cb.stats.bucketBounds, cb.stats.buckets = nil, nil
+			return
+		}
+
+		bounds = slices.Compact(slices.Sorted(slices.Values(bounds)))
+		cb.stats.bucketBounds = bounds
+		cb.stats.buckets = make([]int, len(bounds)+1)
+	}
+}
+
+// latencyBuckets returns successful calls by latency bucket label.
+func (s *callStats) latencyBuckets() map[string]int {
+	if s.buckets == nil {
+		return nil
+	}
+
+	buckets := make(map[string]int, len(s.buckets))
+	for i, bound := range s.bucketBounds {
+		buckets["<"+bound.String()] = s.buckets[i]
+	}
+	n := len(s.bucketBounds)
+	buckets[">="+s.bucketBounds[n-1].String()] = s.buckets[n]
+
+	return buckets
+}
+
+// CallTagged runs `fn` through the circuit breaker like `Call` and accounts
+// its outcome under `tag` in addition to the global counts. Tags don't have
+// state of their own, the breaker opens and recovers as a whole, but `Stats`
+// shows which of the operations drive failures.
+func (cb *CircuitBreaker) CallTagged(tag string, fn operation) (any, error) {
+	res, _, err := cb.call(context.Background(), tag, fn)
+	return res, err
+}
+
+// Stats returns counts of calls made through the circuit breaker.
+func (cb *CircuitBreaker) Stats() Stats {
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	counts := cb.stats.total
+	counts.Rejections += int(cb.stats.fastRejections.Load())
+	times := cb.stats.timeInState
+	times[cb.loadState()] += cb.clock.Now().Sub(cb.stats.stateSince)
+
+	return Stats{
+		Counts:           counts,
+		ByTag:            maps.Clone(cb.stats.byTag),
+		FailuresByReason: maps.Clone(cb.stats.byReason),
+		AvgLatency:       cb.stats.avgLatency,
+		LatencyBuckets:   cb.stats.latencyBuckets(),
+		TimeClosed:       times[StateClosed],
+		TimeOpen:         times[StateOpen],
+		TimeHalfOpen:     times[StateHalfOpen],
+		TimeRecovering:   times[StateRecovering],
+	}
+}
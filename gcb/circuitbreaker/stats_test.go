@@ -0,0 +1,233 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"testing"
+	"time"
+)
+
+func TestStatsByTag(t *testing.T) {
+	cb := NewCircuitBreaker(5, 1, time.Minute, time.Second)
+
+	cb.CallTagged("read", makeService(0, 1, 0))
+	cb.CallTagged("read", makeService(0, 1, 0))
+	cb.CallTagged("write", makeService(0, 1, 100))
+	cb.CallTagged("write", makeService(0, 1, 100))
+	cb.CallTagged("write", makeService(0, 1, 0))
+	cb.Call(makeService(0, 1, 100))
+
+	stats := cb.Stats()
+
+	want := Counts{Requests: 6, Successes: 3, Failures: 3}
+	if stats.Counts != want {
+		t.Errorf("global counts should be `%+v`, got `%+v`", want, stats.Counts)
+	}
+
+	wantByTag := map[string]Counts{
+		"read":  {Requests: 2, Successes: 2},
+		"write": {Requests: 3, Successes: 1, Failures: 2},
+	}
+	if len(stats.ByTag) != len(wantByTag) {
+		t.Fatalf("counts should be kept for tags `read` and `write`, got `%+v`", stats.ByTag)
+	}
+	for tag, want := range wantByTag {
+		if got := stats.ByTag[tag]; got != want {
+			t.Errorf("counts of `%s` should be `%+v`, got `%+v`", tag, want, got)
+		}
+	}
+}
+
+func TestStatsSharedLifecycle(t *testing.T) {
+	cb := NewCircuitBreaker(2, 1, time.Minute, time.Second)
+
+	// Failures of one operation open the breaker for all of them
+	cb.CallTagged("write", makeService(0, 1, 100))
+	cb.CallTagged("write", makeService(0, 1, 100))
+
+	_, err := cb.CallTagged("read", makeService(0, 1, 0))
+	if !errors.Is(err, ErrOpenState) {
+		t.Fatalf("tagged call should be rejected by the open breaker, got `%v`", err)
+	}
+
+	stats := cb.Stats()
+	if got := stats.ByTag["read"]; got != (Counts{Rejections: 1}) {
+		t.Errorf("`read` should be rejected once without requests, got `%+v`", got)
+	}
+	if got := stats.ByTag["write"]; got != (Counts{Requests: 2, Failures: 2}) {
+		t.Errorf("`write` should drive the failures, got `%+v`", got)
+	}
+	if stats.Rejections != 1 {
+		t.Errorf("rejection should be counted globally, got `%d`", stats.Rejections)
+	}
+}
+
+func TestStatsCancellationIsNotFailure(t *testing.T) {
+	cb := NewCircuitBreaker(1, 1, time.Minute, time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	cb.CallContext(ctx, makeService(50, 100, 0))
+
+	if got := cb.Stats().Counts; got != (Counts{Requests: 1}) {
+		t.Errorf("canceled call should count as a request only, got `%+v`", got)
+	}
+}
+
+func TestStatsIsolated(t *testing.T) {
+	cb := NewCircuitBreaker(5, 1, time.Minute, time.Second)
+	cb.CallTagged("read", makeService(0, 1, 0))
+
+	stats := cb.Stats()
+	stats.ByTag["read"] = Counts{}
+
+	if got := cb.Stats().ByTag["read"]; got.Requests != 1 {
+		t.Errorf("modifying returned stats shouldn't affect the breaker, got `%+v`", got)
+	}
+}
+
+func TestLatencyEWMASequence(t *testing.T) {
+	s := callStats{alpha: 0.5}
+
+	for i, tt := range []struct {
+		latency time.Duration
+		want    time.Duration
+	}{
+		{100 * time.Millisecond, 100 * time.Millisecond},
+		{200 * time.Millisecond, 150 * time.Millisecond},
+		{200 * time.Millisecond, 175 * time.Millisecond},
+		{75 * time.Millisecond, 125 * time.Millisecond},
+	} {
+		s.observeLatency(tt.latency)
+		if s.avgLatency != tt.want {
+			t.Errorf("average after call %d should be `%s`, got `%s`", i, tt.want, s.avgLatency)
+		}
+	}
+}
+
+func TestLatencyEWMAConverges(t *testing.T) {
+	s := callStats{alpha: 0.2}
+
+	s.observeLatency(time.Second)
+	for i := 0; i < 50; i++ {
+		s.observeLatency(10 * time.Millisecond)
+	}
+
+	if s.avgLatency < 10*time.Millisecond || s.avgLatency > 11*time.Millisecond {
+		t.Errorf("average should converge to `10ms`, got `%s`", s.avgLatency)
+	}
+}
+
+func TestStatsAvgLatency(t *testing.T) {
+	cb := NewCircuitBreaker(5, 1, time.Minute, time.Second, WithLatencyEWMA(0.5))
+
+	for i := 0; i < 5; i++ {
+		cb.Call(sleepingService(20 * time.Millisecond))
+	}
+	// Failures don't affect the average
+	cb.Call(func() (any, error) {
+		time.Sleep(200 * time.Millisecond)
+		return nil, errors.New("service failed")
+	})
+
+	if got := cb.Stats().AvgLatency; got < 20*time.Millisecond || got > 40*time.Millisecond {
+		t.Errorf("average latency should be about `20ms`, got `%s`", got)
+	}
+}
+
+func TestStatsAvgLatencyDisabled(t *testing.T) {
+	cb := NewCircuitBreaker(5, 1, time.Minute, time.Second)
+	cb.Call(sleepingService(time.Millisecond))
+
+	if got := cb.Stats().AvgLatency; got != 0 {
+		t.Errorf("average latency should be zero unless enabled, got `%s`", got)
+	}
+}
+
+func TestLatencyBuckets(t *testing.T) {
+	var cb CircuitBreaker
+	WithLatencyBuckets([]time.Duration{500 * time.Millisecond, 50 * time.Millisecond, 100 * time.Millisecond})(&cb)
+
+	for _, d := range []time.Duration{
+		0, 49 * time.Millisecond,
+		50 * time.Millisecond,
+		100 * time.Millisecond, 499 * time.Millisecond,
+		500 * time.Millisecond, time.Minute,
+	} {
+		cb.stats.observeLatency(d)
+	}
+
+	want := map[string]int{"<50ms": 2, "<100ms": 1, "<500ms": 2, ">=500ms": 2}
+	if got := cb.stats.latencyBuckets(); !maps.Equal(got, want) {
+		t.Errorf("buckets should be `%v`, got `%v`", want, got)
+	}
+}
+
+func TestStatsLatencyBuckets(t *testing.T) {
+	cb := NewCircuitBreaker(5, 1, time.Minute, time.Second,
+		WithLatencyBuckets([]time.Duration{20 * time.Millisecond, 100 * time.Millisecond}))
+
+	cb.Call(sleepingService(time.Millisecond))
+	cb.Call(sleepingService(time.Millisecond))
+	cb.Call(sleepingService(40 * time.Millisecond))
+	cb.Call(sleepingService(120 * time.Millisecond))
+	// Failures aren't tallied
+	cb.Call(func() (any, error) {
+		return nil, errors.New("service failed")
+	})
+
+	want := map[string]int{"<20ms": 2, "<100ms": 1, ">=100ms": 1}
+	if got := cb.Stats().LatencyBuckets; !maps.Equal(got, want) {
+		t.Errorf("buckets should be `%v`, got `%v`", want, got)
+	}
+}
+
+func TestStatsLatencyBucketsDisabled(t *testing.T) {
+	cb := NewCircuitBreaker(5, 1, time.Minute, time.Second)
+	cb.Call(sleepingService(time.Millisecond))
+
+	if got := cb.Stats().LatencyBuckets; got != nil {
+		t.Errorf("buckets should be nil unless enabled, got `%v`", got)
+	}
+}
+
+func TestStatsFailuresByReason(t *testing.T) {
+	cb := NewCircuitBreaker(10, 1, time.Minute, 20*time.Millisecond)
+
+	cb.Call(makeService(0, 1, 100))
+	cb.Call(makeService(0, 1, 100))
+	cb.Call(sleepingService(50 * time.Millisecond))
+	cb.Call(func() (any, error) {
+		panic("nil map")
+	})
+	cb.Call(makeService(0, 1, 0))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	cb.CallContext(ctx, makeService(0, 1, 100))
+
+	want := map[FailureReason]int{FailureOperationError: 2, FailureTimeout: 1, FailurePanic: 1}
+	if got := cb.Stats().FailuresByReason; !maps.Equal(got, want) {
+		t.Errorf("failures by reason should be `%v`, got `%v`", want, got)
+	}
+}
+
+func TestStatsTimeInState(t *testing.T) {
+	clock := NewFakeClock()
+	cb := NewCircuitBreaker(1, 1, time.Second, time.Second, WithClock(clock))
+
+	clock.Advance(10 * time.Second)
+	cb.Call(makeService(0, 1, 100))
+	clock.Advance(3 * time.Second)
+	// Transition to half open, probe stays there for a while
+	cb.Call(makeService(0, 1, 0))
+	clock.Advance(2 * time.Second)
+	cb.Call(makeService(0, 1, 0))
+	clock.Advance(5 * time.Second)
+
+	stats := cb.Stats()
+	if stats.TimeClosed != 15*time.Second || stats.TimeOpen != 3*time.Second || stats.TimeHalfOpen != 2*time.Second {
+		t.Errorf("time should be accounted per state including the current one, got closed `%s`, open `%s`, half-open `%s`",
+			stats.TimeClosed, stats.TimeOpen, stats.TimeHalfOpen)
+	}
+}
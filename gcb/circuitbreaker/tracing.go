@@ -0,0 +1,45 @@
+package circuitbreaker
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Span attributes of traced calls
+const (
+	attrName    = attribute.Key("circuitbreaker.name")
+	attrState   = attribute.Key("circuitbreaker.state")
+	attrOutcome = attribute.Key("circuitbreaker.outcome")
+)
+
+// WithTracer wraps every call in a span started with `tracer`, carrying the
+// breaker name, the state at entry and the outcome, with the error recorded.
+// The span is a child of the span in the call's context and its context is
+// given to callbacks. Calls aren't traced without a tracer.
+func WithTracer(tracer trace.Tracer) Option {
+	return func(cb *CircuitBreaker) {
+		cb.tracer = tracer
+	}
+}
+
+// tracedCall runs `fn` through the circuit breaker within a span.
+func (cb *CircuitBreaker) tracedCall(ctx context.Context, tag string, fn operation) (any, CallOutcome, error) {
+	ctx, span := cb.tracer.Start(ctx, "circuitbreaker.call", trace.WithAttributes(
+		attrName.String(cb.name),
+		attrState.String(cb.loadState().String()),
+	))
+	defer span.End()
+
+	res, outcome, err := cb.execute(ctx, tag, fn)
+
+	span.SetAttributes(attrOutcome.String(outcome.String()))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	return res, outcome, err
+}
@@ -0,0 +1,87 @@
+package circuitbreaker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// spanAttributes returns the attributes of a recorded span by key.
+func spanAttributes(span sdktrace.ReadOnlySpan) map[attribute.Key]string {
+	attrs := make(map[attribute.Key]string)
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value.Emit()
+	}
+
+	return attrs
+}
+
+func TestTracer(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	defer provider.Shutdown(context.Background())
+
+	cb := NewCircuitBreaker(1, 1, time.Minute, time.Second,
+		WithName("payments"), WithTracer(provider.Tracer("test")))
+
+	cb.Call(makeService(0, 1, 0))
+	cb.Call(makeService(0, 1, 100))
+	cb.Call(makeService(0, 1, 0))
+
+	spans := exporter.GetSpans().Snapshots()
+	if len(spans) != 3 {
+		t.Fatalf("every call should be traced, got %d spans", len(spans))
+	}
+
+	want := []struct {
+		state   string
+		outcome string
+		failed  bool
+	}{
+		{"closed", "executed", false},
+		{"closed", "executed", true},
+		{"open", "rejected-open", true},
+	}
+	for i, span := range spans {
+		attrs := spanAttributes(span)
+		if attrs[attrName] != "payments" || attrs[attrState] != want[i].state || attrs[attrOutcome] != want[i].outcome {
+			t.Errorf("span %d should have state `%s` and outcome `%s`, got `%v`", i, want[i].state, want[i].outcome, attrs)
+		}
+
+		failed := span.Status().Code == codes.Error
+		if failed != want[i].failed || failed != (len(span.Events()) == 1) {
+			t.Errorf("span %d should record the error of failed calls, got `%v` with %d events", i, span.Status(), len(span.Events()))
+		}
+	}
+}
+
+func TestTracerSpanContext(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	defer provider.Shutdown(context.Background())
+	tracer := provider.Tracer("test")
+
+	var observed context.Context
+	cb := NewCircuitBreaker(1, 1, time.Minute, time.Second, WithTracer(tracer),
+		WithObserver(func(ctx context.Context, _ string, _ time.Duration, _ error, _ CallOutcome) {
+			observed = ctx
+		}))
+
+	ctx, parent := tracer.Start(context.Background(), "request")
+	cb.CallContext(ctx, makeService(0, 1, 0))
+	parent.End()
+
+	spans := exporter.GetSpans().Snapshots()
+	if len(spans) != 2 || spans[0].Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Fatalf("call span should be a child of the caller's span, got `%v`", spans)
+	}
+	if got := trace.SpanContextFromContext(observed).SpanID(); got != spans[0].SpanContext().SpanID() {
+		t.Errorf("observer should be given the context of the call span, got `%s`", got)
+	}
+}
@@ -0,0 +1,54 @@
+package circuitbreaker
+
+import "context"
+
+// WithOnStateChange invokes `fn` after every transition with the context of
+// the call which triggered it, e.g. to correlate transitions with requests by
+// trace IDs. Transitions made in the background, e.g. by a health probe or a
+// scheduled recovery, report `context.Background()`. It runs without
+// holding the lock.
+func WithOnStateChange(fn func(ctx context.Context, name string, from, to State)) Option {
+	return func(cb *CircuitBreaker) {
+		cb.onStateChange = fn
+	}
+}
+
+// transition is a state change waiting to be reported.
+type transition struct {
+	ctx      context.Context
+	from, to State
+}
+
+// queueTransition keeps a transition made under the lock to report it once
+// the lock is released.
+func (cb *CircuitBreaker) queueTransition(from, to State) {
+	if cb.onStateChange == nil {
+		return
+	}
+
+	ctx := cb.callCtx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	cb.transitions = append(cb.transitions, transition{ctx, from, to})
+}
+
+// unlock publishes the fast-path rejection deadline, releases the mutex and
+// then reports warnings and transitions queued while holding it.
+func (cb *CircuitBreaker) unlock() {
+	cb.checkWarning()
+	warnings := cb.warnings
+	cb.warnings = nil
+	transitions := cb.transitions
+	cb.transitions = nil
+	cb.callCtx = nil
+	cb.publishRejectUntil()
+	cb.mu.Unlock()
+
+	for _, count := range warnings {
+		cb.onWarning(cb.name, count)
+	}
+	for _, t := range transitions {
+		cb.onStateChange(t.ctx, cb.name, t.from, t.to)
+	}
+}
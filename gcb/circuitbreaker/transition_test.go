@@ -0,0 +1,76 @@
+package circuitbreaker
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// requestIDKey keys a request ID carried by call contexts
+type requestIDKey struct{}
+
+// reported is a transition reported to the callback
+type reported struct {
+	ctx      context.Context
+	from, to State
+}
+
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+func TestOnStateChangeCallContext(t *testing.T) {
+	var got []reported
+	var observed []string
+	var cb *CircuitBreaker
+	cb = NewCircuitBreaker(1, 1, time.Minute, time.Second,
+		WithOnStateChange(func(ctx context.Context, name string, from, to State) {
+			// Taking the lock deadlocks if the callback runs under it
+			cb.Snapshot()
+			got = append(got, reported{ctx, from, to})
+		}),
+		WithObserver(func(ctx context.Context, _ string, _ time.Duration, _ error, _ CallOutcome) {
+			observed = append(observed, requestID(ctx))
+		}),
+	)
+
+	ctx := context.WithValue(context.Background(), requestIDKey{}, "req-1")
+	cb.CallContext(ctx, makeService(0, 1, 100))
+
+	if len(got) != 1 || got[0].from != StateClosed || got[0].to != StateOpen {
+		t.Fatalf("failing call should report a single transition to open, got `%+v`", got)
+	}
+	if id := requestID(got[0].ctx); id != "req-1" {
+		t.Errorf("transition should carry the context of the call which caused it, got `%s`", id)
+	}
+
+	cb.Call(makeService(0, 1, 0))
+	if len(observed) != 2 || observed[0] != "req-1" || observed[1] != "" {
+		t.Errorf("observer should be given the context of each call, got `%v`", observed)
+	}
+}
+
+func TestOnStateChangeBackground(t *testing.T) {
+	var got []reported
+	clock := NewFakeClock()
+	cb := NewCircuitBreaker(1, 1, time.Second, time.Second,
+		WithClock(clock),
+		WithAutoHalfOpen(true),
+		WithOnStateChange(func(ctx context.Context, name string, from, to State) {
+			got = append(got, reported{ctx, from, to})
+		}),
+	)
+	defer cb.Close()
+
+	ctx := context.WithValue(context.Background(), requestIDKey{}, "req-1")
+	cb.CallContext(ctx, makeService(0, 1, 100))
+	clock.Advance(time.Second)
+
+	if len(got) != 2 || got[1].from != StateOpen || got[1].to != StateHalfOpen {
+		t.Fatalf("scheduled recovery should report a transition to half open, got `%+v`", got)
+	}
+	if got[1].ctx != context.Background() {
+		t.Errorf("background transition should report the background context, got `%v`", got[1].ctx)
+	}
+}
@@ -0,0 +1,56 @@
+package circuitbreaker
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Try admits a call without running anything, for operations which don't fit
+// `Call`, e.g. streaming or multi-step ones run inline. When `ok` the caller
+// runs the operation and reports its error to `permit`, which accounts for
+// the outcome like `Call` would, taking the `half-open` probe slot meanwhile.
+//
+// The timeout isn't enforced on the operation, but a permit which isn't
+// reported within the timeout, measured on the breaker clock, is accounted
+// as timed out, so a forgotten permit doesn't hold the probe slot forever.
+// Reporting after that, or more than once, has no effect.
+func (cb *CircuitBreaker) Try() (permit func(err error), ok bool) {
+	permit, _ = cb.try(true)
+	return permit, permit != nil
+}
+
+// try admits a call like `Try`, returning the rejection error if the call
+// isn't admitted. The error is `nil` for a call which only transitioned the
+// breaker. Unless `expire` the permit is never accounted as timed out.
+func (cb *CircuitBreaker) try(expire bool) (func(err error), error) {
+	ctx := context.Background()
+	t, _, err := cb.enter(ctx, "")
+	if !t.run {
+		return nil, err
+	}
+
+	start := time.Now()
+	var once sync.Once
+	finish := func(err error) {
+		once.Do(func() {
+			elapsed := time.Since(start)
+			_, err = cb.record(ctx, t, nil, elapsed, err)
+			cb.leave()
+			cb.observe(ctx, elapsed, err, executionOutcome(ctx, t, err))
+		})
+	}
+
+	if !expire {
+		return finish, nil
+	}
+
+	timer := cb.clock.AfterFunc(t.timeout, func() {
+		finish(cb.errorf("%w", ErrTimeout))
+	})
+
+	return func(err error) {
+		timer.Stop()
+		finish(err)
+	}, nil
+}
@@ -0,0 +1,69 @@
+package circuitbreaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTry(t *testing.T) {
+	clock := NewFakeClock()
+	cb := NewCircuitBreaker(2, 1, time.Second, time.Minute, WithClock(clock))
+
+	for i := 0; i < 2; i++ {
+		permit, ok := cb.Try()
+		if !ok {
+			t.Fatalf("closed breaker should admit the call")
+		}
+		permit(errors.New("stream broken"))
+	}
+	if _, ok := cb.Try(); ok || cb.State() != StateOpen {
+		t.Fatalf("reported failures should open the breaker, got `%s`", cb.State())
+	}
+
+	clock.Advance(2 * time.Second)
+	// Transition to half open state
+	cb.Try()
+	probe, ok := cb.Try()
+	if !ok {
+		t.Fatalf("half open breaker should admit the probe")
+	}
+	if _, ok := cb.Try(); ok {
+		t.Errorf("probe slot should be taken until the permit is reported")
+	}
+
+	probe(nil)
+	probe(errors.New("reported twice"))
+	if cb.State() != StateClosed {
+		t.Errorf("successful probe should close the breaker, got `%s`", cb.State())
+	}
+}
+
+func TestTryForgottenPermit(t *testing.T) {
+	clock := NewFakeClock()
+	cb := NewCircuitBreaker(1, 1, time.Second, 5*time.Second, WithClock(clock))
+
+	permit, _ := cb.Try()
+	permit(errors.New("stream broken"))
+	clock.Advance(2 * time.Second)
+	cb.Try()
+
+	// Probe permit is never reported
+	late, ok := cb.Try()
+	if !ok {
+		t.Fatalf("half open breaker should admit the probe")
+	}
+
+	clock.Advance(5 * time.Second)
+	if cb.State() != StateOpen {
+		t.Errorf("unreported probe should time out and re-open the breaker, got `%s`", cb.State())
+	}
+	if timeouts := cb.Stats().FailuresByReason[FailureTimeout]; timeouts != 1 {
+		t.Errorf("unreported probe should count as timed out, got %d timeouts", timeouts)
+	}
+
+	late(nil)
+	if cb.State() != StateOpen {
+		t.Errorf("permit reported after the timeout should have no effect, got `%s`", cb.State())
+	}
+}
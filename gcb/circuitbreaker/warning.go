@@ -0,0 +1,33 @@
+package circuitbreaker
+
+import "log/slog"
+
+// WithWarningThreshold invokes `fn` once the failure count reaches `n` in
+// `closed` state, giving early warning before the breaker opens. It fires
+// once per crossing and re-arms when the count drops below `n` again, e.g.
+// after a success. `n` should be below the failure threshold, zero disables
+// the warning. It runs without holding the lock.
+func WithWarningThreshold(n int, fn func(name string, failureCount int)) Option {
+	return func(cb *CircuitBreaker) {
+		cb.warningThreshold = n
+		cb.onWarning = fn
+	}
+}
+
+// checkWarning arms or fires the warning for the current failure count. Must
+// be called with the lock held, the warning is reported once it's released.
+func (cb *CircuitBreaker) checkWarning() {
+	if cb.onWarning == nil || cb.warningThreshold <= 0 || cb.loadState() != StateClosed {
+		return
+	}
+
+	switch {
+	case cb.failureCount < cb.warningThreshold:
+		cb.warned = false
+	case !cb.warned:
+		slog.Warn("failure warning threshold reached", "name", cb.name, "state", cb.loadState(),
+			"count", cb.failureCount)
+		cb.warned = true
+		cb.warnings = append(cb.warnings, cb.failureCount)
+	}
+}
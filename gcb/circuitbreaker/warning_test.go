@@ -0,0 +1,59 @@
+package circuitbreaker
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestWarningThreshold(t *testing.T) {
+	var warnings []int
+	var cb *CircuitBreaker
+	cb = NewCircuitBreaker(5, 1, time.Minute, time.Second,
+		WithName("payments"),
+		WithWarningThreshold(3, func(name string, failureCount int) {
+			// Taking the lock deadlocks if the callback runs under it
+			cb.Snapshot()
+			if name != "payments" {
+				t.Errorf("warning should be given the breaker name, got `%s`", name)
+			}
+			warnings = append(warnings, failureCount)
+		}),
+	)
+
+	callOutcomes(cb, false, false)
+	if len(warnings) != 0 {
+		t.Fatalf("warning shouldn't fire below the threshold, got `%v`", warnings)
+	}
+
+	callOutcomes(cb, false, false)
+	if !slices.Equal(warnings, []int{3}) {
+		t.Fatalf("warning should fire once at the threshold, got `%v`", warnings)
+	}
+
+	// Success clears failures, re-arming the warning
+	callOutcomes(cb, true, false, false, false)
+	if !slices.Equal(warnings, []int{3, 3}) {
+		t.Errorf("warning should fire again after failures cleared, got `%v`", warnings)
+	}
+	if cb.State() != StateClosed {
+		t.Errorf("warning shouldn't open the breaker, got `%s`", cb.State())
+	}
+}
+
+func TestWarningThresholdRecovery(t *testing.T) {
+	clock := NewFakeClock()
+	warnings := 0
+	cb := NewCircuitBreaker(2, 1, time.Second, time.Second, WithClock(clock),
+		WithWarningThreshold(1, func(string, int) { warnings++ }))
+
+	callOutcomes(cb, false, false)
+	clock.Advance(2 * time.Second)
+	// Transition to half open, successful probe closes the breaker
+	callOutcomes(cb, true, true)
+	callOutcomes(cb, false)
+
+	if warnings != 2 {
+		t.Errorf("warning should re-arm once the breaker recovered, got %d warnings", warnings)
+	}
+}
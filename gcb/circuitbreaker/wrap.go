@@ -0,0 +1,29 @@
+package circuitbreaker
+
+// CallWithResult runs `fn` through `cb` like `Call`, returning its result
+// typed. Calls which didn't produce a result, e.g. rejected, timed out or
+// transitioning ones, return the zero value of `T` along with the error, so
+// `errors.Is(err, ErrOpenState)` and similar hold as for `Call`.
+func CallWithResult[T any](cb *CircuitBreaker, fn func() (T, error)) (T, error) {
+	var op operation
+	if fn != nil {
+		// Nil operation is refused by the breaker
+		op = func() (any, error) {
+			return fn()
+		}
+	}
+
+	res, err := cb.Call(op)
+
+	// Calls without a result carry `nil`, which isn't a `T`
+	v, _ := res.(T)
+	return v, err
+}
+
+// Wrap returns `fn` protected by `cb`, calling the returned function runs
+// `fn` through the breaker like `CallWithResult`.
+func Wrap[T any](cb *CircuitBreaker, fn func() (T, error)) func() (T, error) {
+	return func() (T, error) {
+		return CallWithResult(cb, fn)
+	}
+}
@@ -0,0 +1,121 @@
+package circuitbreaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type quote struct {
+	symbol string
+	price  float64
+}
+
+func TestWrap(t *testing.T) {
+	clock := NewFakeClock()
+	cb := NewCircuitBreaker(2, 1, time.Second, time.Second, WithClock(clock))
+
+	failing := true
+	fetch := Wrap(cb, func() (quote, error) {
+		if failing {
+			return quote{}, errors.New("exchange unavailable")
+		}
+		return quote{"ACME", 42.5}, nil
+	})
+
+	fetch()
+	fetch()
+	if cb.State() != StateOpen {
+		t.Fatalf("failures of wrapped function should open the breaker, got `%s`", cb.State())
+	}
+
+	q, err := fetch()
+	if !errors.Is(err, ErrOpenState) || q != (quote{}) {
+		t.Errorf("rejected call should return the zero value, got `%+v`, `%v`", q, err)
+	}
+
+	failing = false
+	clock.Advance(2 * time.Second)
+	// Transition to half open state
+	fetch()
+
+	q, err = fetch()
+	if err != nil {
+		t.Fatalf("recovered function shouldn't fail, got `%v`", err)
+	}
+	if q.symbol != "ACME" || q.price != 42.5 {
+		t.Errorf("typed result should be returned, got `%+v`", q)
+	}
+	if cb.State() != StateClosed {
+		t.Errorf("successful probe should close the breaker, got `%s`", cb.State())
+	}
+}
+
+func TestWrapResultOnError(t *testing.T) {
+	cb := NewCircuitBreaker(5, 1, time.Second, time.Second)
+
+	fetch := Wrap(cb, func() (*quote, error) {
+		return &quote{symbol: "ACME"}, errors.New("stale price")
+	})
+
+	q, err := fetch()
+	if err == nil || q == nil || q.symbol != "ACME" {
+		t.Errorf("result returned with the error should pass through, got `%+v`, `%v`", q, err)
+	}
+}
+
+// rejectedResult opens `cb` and returns the result of a rejected call.
+func rejectedResult[T any](t *testing.T, cb *CircuitBreaker) (T, error) {
+	t.Helper()
+
+	cb.Call(makeService(0, 1, 100))
+	if cb.State() != StateOpen {
+		t.Fatalf("failure should open the breaker, got `%s`", cb.State())
+	}
+
+	return CallWithResult(cb, func() (T, error) {
+		t.Errorf("operation shouldn't run while open")
+		var zero T
+		return zero, nil
+	})
+}
+
+func TestCallWithResultRejected(t *testing.T) {
+	n, err := rejectedResult[int](t, NewCircuitBreaker(1, 1, time.Minute, time.Second))
+	if n != 0 || !errors.Is(err, ErrOpenState) {
+		t.Errorf("rejected int call should return zero, got `%d`, `%v`", n, err)
+	}
+
+	q, err := rejectedResult[quote](t, NewCircuitBreaker(1, 1, time.Minute, time.Second))
+	if q != (quote{}) || !errors.Is(err, ErrOpenState) {
+		t.Errorf("rejected struct call should return zero, got `%+v`, `%v`", q, err)
+	}
+
+	s, err := rejectedResult[[]string](t, NewCircuitBreaker(1, 1, time.Minute, time.Second))
+	if s != nil || !errors.Is(err, ErrOpenState) {
+		t.Errorf("rejected slice call should return nil, got `%v`, `%v`", s, err)
+	}
+}
+
+func TestCallWithResultTimeout(t *testing.T) {
+	cb := NewCircuitBreaker(5, 1, time.Minute, 10*time.Millisecond)
+
+	q, err := CallWithResult(cb, func() (quote, error) {
+		time.Sleep(50 * time.Millisecond)
+		return quote{"ACME", 42.5}, nil
+	})
+	if q != (quote{}) || !errors.Is(err, ErrTimeout) {
+		t.Errorf("timed out call should return zero, got `%+v`, `%v`", q, err)
+	}
+}
+
+func TestCallWithResult(t *testing.T) {
+	cb := NewCircuitBreaker(5, 1, time.Minute, time.Second)
+
+	s, err := CallWithResult(cb, func() ([]string, error) {
+		return []string{"ACME"}, nil
+	})
+	if err != nil || len(s) != 1 || s[0] != "ACME" {
+		t.Errorf("typed result should be returned, got `%v`, `%v`", s, err)
+	}
+}